@@ -2,9 +2,12 @@ package unpuzzled
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"html/template"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/olekukonko/tablewriter"
 	log "github.com/sirupsen/logrus"
@@ -18,7 +21,7 @@ type App struct {
 	// The text used for the copyright section in the help text.
 	Copyright string
 	// The order in which variable sources will be parsed, values later in the array will be parsed afterwards, overwriting earlier sources.
-	// Default order is: CLI Flag > Toml Config > JSON Config > Environment
+	// Default order is: CLI Flag > Config Directory > Yaml Config > Toml Config > JSON Config > Secret File > Environment > Dotenv Config
 	ParsingOrder []ParsingType
 	// Main command attached to the app.
 	Command *Command
@@ -33,11 +36,29 @@ type App struct {
 	// All output will not include color
 	RemoveColor bool
 	// Turn off all output
-	Silent                   bool
+	Silent bool
+	// Added to the environment variable names, ex. "MYAPP_" to use MYAPP_TEST_VALUE for the test-value variable.
+	EnvPrefix string
+	// Include the subcommand path in environment variable names, ex. MYAPP_SERVE_PORT for the port variable of the serve subcommand.
+	EnvCommandPath bool
+	// Called after a reload that changed any variable, see App.Reload and App.Watch.
+	OnChange func(changes []*Change)
+	// Called by App.Watch when a reload fails, the previous values are kept.
+	OnReloadError            func(err error)
 	args                     []string
 	activeCommands           []*Command
 	missingRequiredVariables map[string][]Variable
+	parseErrors              ParseErrors
 	settingsMap              *mappedSettings
+	configLoaders            map[ParsingType]ConfigLoader
+	sourceNames              map[ParsingType]string
+	keyValueSources          map[ParsingType]*namedKeyValueSource
+	ctx                      context.Context
+	mutex                    sync.RWMutex
+	warnings                 []*Warning
+	validationErrors         ValidationErrors
+	constraintGroupErrors    ConstraintGroupErrors
+	initialValues            map[Variable]interface{}
 }
 
 type ParsingType int
@@ -48,14 +69,25 @@ const (
 	TomlConfig
 	CliFlags
 	DefaultValue
+	YamlConfig
+	ConfigSearchPath
+	ConfigDirectory
+	DotEnvConfig
+	SecretFile
 )
 
+// Names of the built in sources. Names of registered loaders and sources are kept on the App.
 var ParsingTypeStringMap = map[ParsingType]string{
 	EnvironmentVariables: "Environment",
 	JsonConfig:           "JSON Config",
 	TomlConfig:           "Toml Config",
 	CliFlags:             "CLI Flag",
 	DefaultValue:         "Default Value",
+	YamlConfig:           "Yaml Config",
+	ConfigSearchPath:     "Config Search Path",
+	ConfigDirectory:      "Config Directory",
+	DotEnvConfig:         "Dotenv Config",
+	SecretFile:           "Secret File",
 }
 
 // Create a new application with default values set.
@@ -69,9 +101,13 @@ func NewApp() *App {
 			"help":   true,
 		},
 		ParsingOrder: []ParsingType{
+			DotEnvConfig,
 			EnvironmentVariables,
+			SecretFile,
 			JsonConfig,
 			TomlConfig,
+			YamlConfig,
+			ConfigDirectory,
 			CliFlags,
 		},
 		HelpTextVariablesInTable: true,
@@ -81,48 +117,179 @@ func NewApp() *App {
 // Run the app. Should be called with:
 // app := cli.NewApp()
 // app.Run(os.Args)
+//
+// Run exits the process if RunE returns an error, use RunE to handle errors instead.
 func (a *App) Run(args []string) {
+	err := a.RunE(args)
+	if err == nil {
+		return
+	}
+	if err == ErrHelpRequested {
+		os.Exit(0)
+	}
+	// missing variables, parse errors, constraint group and validation errors are already printed by RunE.
+	switch err.(type) {
+	case *MissingRequiredError, ParseErrors, ConstraintGroupErrors, ValidationErrors:
+		os.Exit(1)
+	}
+	if exitCoder, ok := err.(ExitCoder); ok {
+		if !a.Silent {
+			fmt.Fprintln(os.Stderr, exitCoder.Error())
+		}
+		os.Exit(exitCoder.ExitCode())
+	}
+	log.WithFields(log.Fields{"err": err}).Fatal("Failed to run the app.")
+}
+
+// Run the app, returning an error instead of exiting the process.
+// Errors are one of: ErrHelpRequested, *MissingRequiredError, ParseErrors, ConstraintGroupErrors, ValidationErrors,
+// *DuplicateVariableError, *ConfigError, or the error returned from Command.ActionE.
+// The app can be run again, variables that aren't set by the new run are reset to their value before the first run.
+func (a *App) RunE(args []string) error {
+	return a.RunContext(context.Background(), args)
+}
+
+// Same as RunE, ctx is passed to Command.ActionE through Context.Context().
+func (a *App) RunContext(ctx context.Context, args []string) error {
 	if len(args) < 1 {
-		log.Fatal("Arguments must be at least 1, please run with app.Run(os.Args).")
+		return ErrNoArguments
 	}
 	a.args = args[1:]
-	a.parseCommands()
-	if a.checkRequiredVariables(); a.missingRequiredVariables != nil {
-		a.PrintMissingRequiredVariables()
-		os.Exit(1)
+	a.ctx = ctx
+	a.parseErrors = nil
+	if err := a.parseCommands(); err != nil {
+		if parseErrors, ok := err.(ParseErrors); ok {
+			a.parseErrors = parseErrors
+			a.PrintParseErrors()
+		}
+		return err
+	}
+	if err := a.checkVariables(); err != nil {
+		return err
 	}
 	a.printOverrides()
 
-	finalCommand := a.activeCommands[len(a.activeCommands)-1]
-	if finalCommand.Action != nil {
-		finalCommand.Action()
+	return a.runActiveCommands(ctx)
+}
+
+// Run the BeforeFunc of every active command top-down, the action of the final command,
+// then the AfterFunc of every command bottom-up. The first error is returned.
+func (a *App) runActiveCommands(ctx context.Context) error {
+	var err error
+	entered := 0
+	for _, command := range a.activeCommands {
+		if command.BeforeFunc != nil {
+			if err = command.BeforeFunc(command); err != nil {
+				break
+			}
+		}
+		entered++
+	}
+
+	if err == nil {
+		finalCommand := a.activeCommands[len(a.activeCommands)-1]
+		if finalCommand.ActionE != nil {
+			err = finalCommand.ActionE(newContext(ctx, a))
+		} else if finalCommand.Action != nil {
+			finalCommand.Action()
+		}
+	}
+
+	for i := entered - 1; i >= 0; i-- {
+		command := a.activeCommands[i]
+		if command.AfterFunc == nil {
+			continue
+		}
+		if afterErr := command.AfterFunc(command); afterErr != nil && err == nil {
+			err = afterErr
+		}
 	}
+	return err
 }
 
-func (a *App) parseCommands() {
+// Parse every source, all invalid values are collected and returned together as ParseErrors.
+func (a *App) parseCommands() error {
 	if a.Command == nil {
-		log.Fatal("No command attached to the app!")
+		return ErrNoCommand
 	}
+	if a.ParsingOrder == nil {
+		return ErrNoParsingOrder
+	}
+	a.Command.resetRunState()
 	a.Command.buildTree(nil)
 	a.Command.assignArguments(a.args)
 	a.activeCommands = a.Command.GetActiveCommands()
 	a.Command.findConfigVars()
+	a.saveInitialValues()
 
 	if helpCommand, isHelp := a.Command.isHelpCommand(a.HelpCommands); isHelp {
 		a.PrintHelpCommand(helpCommand)
-		os.Exit(0)
+		return ErrHelpRequested
 	}
 
-	if err := a.Command.parseFlags(); err != nil {
-		log.WithFields(log.Fields{"err": err}).Fatal("error parsing flags.")
-		return
+	if err := a.Command.checkDuplicateVariables(); err != nil {
+		return err
+	}
+	if err := a.Command.checkReplacements(); err != nil {
+		return err
 	}
+	if err := a.Command.checkConstraints(); err != nil {
+		return err
+	}
+	if err := a.Command.checkConstraintGroups(); err != nil {
+		return err
+	}
+	a.warnings = nil
 
-	a.Command.parseConfigVars()
+	parseErrors := a.Command.parseFlags()
+	sourceErrors, err := a.parseSources()
+	if err != nil {
+		return err
+	}
+	parseErrors = append(parseErrors, sourceErrors...)
+	if len(parseErrors) > 0 {
+		a.nameParseErrors(parseErrors)
+		a.maskParseErrors(parseErrors)
+		return parseErrors
+	}
+	return nil
+}
+
+// Load the config files, parse every source in the ParsingOrder and apply the values. Used on every reload.
+func (a *App) parseSources() (ParseErrors, error) {
+	if err := a.Command.parseConfigVars(a, a.getEnvNames); err != nil {
+		if configErr, ok := err.(*ConfigError); ok {
+			configErr.TypeName = a.sourceNames[configErr.Type]
+		}
+		return nil, err
+	}
 	a.Command.applyDefaultValues()
-	a.parseByOrder()
-	a.applySettingsMap()
+	parseErrors := a.parseByOrder()
+	a.settingsMap.markSensitive(a.activeCommands)
+	parseErrors = append(parseErrors, a.applySettingsMap()...)
+	a.resetUnsetVariables()
 	a.settingsMap.checkDuplicatePointers(a.activeCommands)
+	return parseErrors, nil
+}
+
+// Check the required variables, constraint groups and validation once every source is applied, printing the failures.
+func (a *App) checkVariables() error {
+	a.missingRequiredVariables, a.constraintGroupErrors, a.validationErrors = nil, nil, nil
+	if a.checkRequiredVariables(); a.missingRequiredVariables != nil {
+		a.PrintMissingRequiredVariables()
+		return &MissingRequiredError{Variables: a.missingRequiredVariables}
+	}
+	if groupErrors := a.checkConstraintGroups(); len(groupErrors) > 0 {
+		a.constraintGroupErrors = groupErrors
+		a.PrintConstraintGroupErrors()
+		return groupErrors
+	}
+	if validationErrors := a.validateVariables(); len(validationErrors) > 0 {
+		a.validationErrors = validationErrors
+		a.PrintValidationErrors()
+		return validationErrors
+	}
+	return nil
 }
 
 func (a *App) checkRequiredVariables() {
@@ -148,10 +315,11 @@ func (a *App) printOverrides() {
 	}
 	a.settingsMap.OrderSettings(a.activeCommands)
 	if a.OverridesOutputInTable {
-		a.settingsMap.PrintDuplicates(a.activeCommands)
+		a.settingsMap.PrintDuplicates(a.sourceName)
 	} else {
-		a.settingsMap.PrintDuplicatesStdout(a.RemoveColor)
+		a.settingsMap.PrintDuplicatesStdout(a.RemoveColor, a.sourceName)
 	}
+	a.PrintWarnings()
 }
 
 func (a *App) PrintMissingRequiredVariables() {
@@ -177,19 +345,139 @@ func (a *App) PrintMissingRequiredVariables() {
 	t.Execute(os.Stdout, a.missingRequiredVariables)
 }
 
+// Print every value that failed to parse, grouped by command.
+func (a *App) PrintParseErrors() {
+	if a.Silent {
+		return
+	}
+	if a.parseErrors == nil {
+		panic("There are no parse errors.")
+	}
+	a.printCommandErrors("Failed To Parse Variables:", `{{ if gt (len $err.Variable) 0 }}{{ green "--"}}{{ green $err.Variable }} = {{ noEscape (printf "%q" $err.RawValue) }} {{ end }}({{ sourceString $err.Source }}) : {{ noEscape (printf "%v" $err.Err) }}
+`, a.parseErrors)
+}
+
+// Print a list of errors grouped by command, with the template for a single error $err.
+func (a *App) printCommandErrors(title string, errorTemplate string, errs interface{}) {
+	t := template.New("command-errors")
+	funcMap := getBaseFuncMap(a.RemoveColor)
+	funcMap["sourceString"] = func(p ParsingType) string {
+		return a.sourceName(p)
+	}
+	t.Funcs(funcMap)
+	t.Parse(`---------------------------
+{{ bold (red .Title) }}
+---------------------------
+{{ range $k, $errs := .Errors }}
+{{ blue "Command" }} : {{ $k }}
+{{ range $i, $err := $errs -}}
+` + errorTemplate + `{{ end -}}
+{{ end }}
+`)
+	t.Execute(os.Stdout, &commandErrorsOutput{
+		Title:  title,
+		Errors: groupByCommand(errs),
+	})
+}
+
+type commandErrorsOutput struct {
+	Title  string
+	Errors map[string][]commandError
+}
+
+// Set the source names of parse errors, for sources registered with the App.
+func (a *App) nameParseErrors(parseErrors ParseErrors) {
+	for _, parseError := range parseErrors {
+		parseError.SourceName = a.sourceNames[parseError.Source]
+	}
+}
+
 type helpStruct struct {
 	App          *App
 	HelpCommand  *Command
 	ParsingOrder []string
 	UseTable     bool
+	ConfigFiles  []*helpConfigFile
+}
+
+// Config file lookup shown in the help text.
+type helpConfigFile struct {
+	Name     string
+	Type     string
+	Path     string
+	Source   string
+	Searched []string
+}
+
+// The flags for a variable in the help text, ex. "--port, --port-number, -p"
+func getFlagString(variable Variable) string {
+	names := flagNames(variable)
+	flags := make([]string, len(names))
+	for i, name := range names {
+		flags[i] = "--" + name
+	}
+	if short := variable.options().Short; short != "" {
+		flags[len(flags)-1] = "-" + short
+	}
+	return strings.Join(flags, ", ")
+}
+
+// The description in the help text, with the constraint hints.
+func getHelpDescription(variable Variable) string {
+	hints := getConstraintHints(variable)
+	if hints == "" {
+		return variable.GetDescription()
+	}
+	if variable.GetDescription() == "" {
+		return "(" + hints + ")"
+	}
+	return variable.GetDescription() + " (" + hints + ")"
+}
+
+// Resolve the config files for the help command and its parents, flags are not parsed for help.
+func (a *App) getHelpConfigFiles(command *Command) []*helpConfigFile {
+	var configFiles []*helpConfigFile
+	for curr := command; curr != nil; curr = curr.parentCommand {
+		for _, variable := range curr.Variables {
+			config, ok := variable.(*ConfigVariable)
+			if !ok {
+				continue
+			}
+			envNames := a.getEnvNames(curr, config)
+			configFile := &helpConfigFile{
+				Name:     config.Name,
+				Type:     a.sourceName(config.Type),
+				Searched: []string{"--" + config.Name},
+			}
+			for _, envName := range envNames {
+				configFile.Searched = append(configFile.Searched, "$"+envName)
+			}
+			if config.Default != "" {
+				configFile.Searched = append(configFile.Searched, config.Default)
+			}
+			configFile.Searched = append(configFile.Searched, config.getSearchPaths()...)
+			if paths, source, err := config.resolvePaths(curr.flagSet, envNames); err == nil {
+				configFile.Path = strings.Join(paths, ", ")
+				configFile.Source = a.sourceName(source)
+			}
+			configFiles = append([]*helpConfigFile{configFile}, configFiles...)
+		}
+	}
+	return configFiles
 }
 
 func (a *App) PrintHelpCommand(command *Command) {
+	if a.Silent {
+		return
+	}
 	t := template.New("required-variables")
 	funcMap := getBaseFuncMap(a.RemoveColor)
 	funcMap["sourceString"] = func(p ParsingType) string {
-		return ParsingTypeStringMap[p]
+		return a.sourceName(p)
 	}
+	funcMap["join"] = strings.Join
+	funcMap["flagString"] = getFlagString
+	funcMap["helpDescription"] = getHelpDescription
 	funcMap["variableTable"] = func(command *Command) string {
 		buffer := new(bytes.Buffer)
 		table := tablewriter.NewWriter(buffer)
@@ -202,7 +490,9 @@ func (a *App) PrintHelpCommand(command *Command) {
 		})
 		for _, variable := range command.Variables {
 			defaultValue := "--"
-			if varDefault, set := variable.GetDefault(); set {
+			if varDefault, set := variable.GetDefault(); set && isSensitive(variable) {
+				defaultValue = maskedValue
+			} else if set {
 				defaultValue = fmt.Sprintf("%v", varDefault)
 			}
 			required := "No"
@@ -210,11 +500,11 @@ func (a *App) PrintHelpCommand(command *Command) {
 				required = "Required"
 			}
 			row := []string{
-				"--" + variable.GetName(),
+				getFlagString(variable),
 				defaultValue,
 				required,
-				convertNameToOS(variable.GetName()),
-				variable.GetDescription(),
+				strings.Join(a.getEnvNames(command, variable), ", "),
+				getHelpDescription(variable),
 			}
 			table.Append(row)
 		}
@@ -255,7 +545,14 @@ func (a *App) PrintHelpCommand(command *Command) {
 {{ noEscape (variableTable .HelpCommand) }}
 {{ else -}}
 {{ range $i, $v := .HelpCommand.Variables -}}
-{{ blue "--"}}{{ blue $v.GetName }} {{ if $v.IsRequired }}({{ red "Required" }}) {{ end }}{{ noEscape $v.GetDescription }}
+{{ blue (flagString $v) }} {{ if $v.IsRequired }}({{ red "Required" }}) {{ end }}{{ noEscape (helpDescription $v) }}
+{{ end -}}
+{{ end -}}
+{{ if gt (len .ConfigFiles) 0 }}
+{{ bold (green "CONFIGURATION FILES:")}}
+{{ range $i, $c := .ConfigFiles -}}
+{{ blue "--"}}{{ blue $c.Name }} ({{ $c.Type }}) : {{ if gt (len $c.Path) 0 }}{{ noEscape $c.Path }} (set from {{ $c.Source }}){{ else }}{{ red "not found" }}{{ end }}
+	searched: {{ noEscape (join $c.Searched " > ") }}
 {{ end -}}
 {{ end -}}
 {{ if gt (len .App.Copyright) 0 }}{{ bold (green "Copyright:") }}
@@ -269,7 +566,7 @@ func (a *App) PrintHelpCommand(command *Command) {
 
 	parsingOrder := []string{}
 	for _, val := range a.ParsingOrder {
-		parsingOrder = append(parsingOrder, ParsingTypeStringMap[val])
+		parsingOrder = append(parsingOrder, a.sourceName(val))
 	}
 	reverseStringSlice(parsingOrder)
 	t.Execute(os.Stdout, &helpStruct{
@@ -277,48 +574,155 @@ func (a *App) PrintHelpCommand(command *Command) {
 		HelpCommand:  command,
 		ParsingOrder: parsingOrder,
 		UseTable:     a.HelpTextVariablesInTable,
+		ConfigFiles:  a.getHelpConfigFiles(command),
 	})
 }
 
+// Register a loader for a configuration format, used by ConfigVariables with the same Type.
+// The loader's Name is used for the type in the help text and the override output.
+// If the type isn't in the ParsingOrder, it's added before CliFlags.
+func (a *App) RegisterConfigLoader(t ParsingType, loader ConfigLoader) {
+	if a.configLoaders == nil {
+		a.configLoaders = make(map[ParsingType]ConfigLoader)
+	}
+	a.configLoaders[t] = loader
+	a.setSourceName(t, loader.Name())
+	a.addParsingType(t)
+}
+
+// Set the name of a custom source, used in the help text, the override output and errors.
+func (a *App) setSourceName(t ParsingType, name string) {
+	if a.sourceNames == nil {
+		a.sourceNames = make(map[ParsingType]string)
+	}
+	a.sourceNames[t] = name
+}
+
+// The name of a source, names set for this App are checked before ParsingTypeStringMap.
+func (a *App) sourceName(t ParsingType) string {
+	if name, ok := a.sourceNames[t]; ok {
+		return name
+	}
+	return ParsingTypeStringMap[t]
+}
+
+// Add a custom type to the ParsingOrder before CliFlags, if it isn't already in the order.
+func (a *App) addParsingType(t ParsingType) {
+	for _, order := range a.ParsingOrder {
+		if order == t {
+			return
+		}
+	}
+	parsingOrder := make([]ParsingType, 0, len(a.ParsingOrder)+1)
+	added := false
+	for _, order := range a.ParsingOrder {
+		if order == CliFlags && !added {
+			parsingOrder = append(parsingOrder, t)
+			added = true
+		}
+		parsingOrder = append(parsingOrder, order)
+	}
+	if !added {
+		parsingOrder = append(parsingOrder, t)
+	}
+	a.ParsingOrder = parsingOrder
+}
+
+// The context passed to RunContext, used by key-value sources.
+func (a *App) context() context.Context {
+	if a.ctx == nil {
+		return context.Background()
+	}
+	return a.ctx
+}
+
+// Get the registered loader for a config type, falling back to the built in loaders.
+func (a *App) getConfigLoader(t ParsingType) ConfigLoader {
+	if loader, ok := a.configLoaders[t]; ok {
+		return loader
+	}
+	return defaultLoaders{}.getConfigLoader(t)
+}
+
+// The environment variable names for a variable, in the order they're checked.
+// The variable's EnvName replaces the generated name, names for the Aliases and the EnvAliases are checked afterwards.
+func (a *App) getEnvNames(command *Command, variable Variable) []string {
+	prefix := a.EnvPrefix
+	if a.EnvCommandPath && command != nil && command.parentCommand != nil {
+		// the main command is left out, it's usually the name of the app.
+		path := strings.SplitN(command.GetExpandedName(), ".", 2)[1]
+		prefix += convertNameToOS(path) + "_"
+	}
+	return getPrefixedEnvNames(prefix, variable)
+}
+
+// The environment variable names for a variable, generated names start with the prefix.
+func getPrefixedEnvNames(prefix string, variable Variable) []string {
+	options := variable.options()
+	names := []string{options.EnvName}
+	if options.EnvName == "" {
+		names[0] = prefix + convertNameToOS(variable.GetName())
+	}
+	for _, alias := range options.Aliases {
+		names = append(names, prefix+convertNameToOS(alias))
+	}
+	return append(names, options.EnvAliases...)
+}
+
+// Get the loader for a file in a config directory, registered loaders are checked before the built in loaders.
+func (a *App) getExtensionLoader(ext string) ConfigLoader {
+	if loader := findExtensionLoader(a.configLoaders, ext); loader != nil {
+		return loader
+	}
+	return defaultLoaders{}.getExtensionLoader(ext)
+}
+
 // use the set Parsing order to apply the variables in place, adding it to the settings map.
 // The last entries in the settingsMap are the selected variables.
-func (a *App) parseByOrder() {
+// Invalid values are skipped, and returned in ParseErrors.
+func (a *App) parseByOrder() ParseErrors {
 	settingsMap := newMappedSettings()
-	if a.ParsingOrder == nil {
-		log.Fatal("No parsing order! Use unpuzzled.NewApp when creating an application.")
-	}
+	var parseErrors ParseErrors
 
 	settingsMap.addParsedArray(a.Command.getDefaultValues())
 
 	for _, order := range a.ParsingOrder {
 		switch order {
 		case EnvironmentVariables:
-			settingsMap.addParsedArray(a.Command.parseEnvVars())
+			setValues, errs := a.Command.parseEnvVars(a.getEnvNames)
+			parseErrors = append(parseErrors, errs...)
+			settingsMap.addParsedArray(a.checkDeprecated(setValues))
 
-		case JsonConfig:
-			vars := a.Command.getConfigVarsByType(JsonConfig)
-			if len(vars) == 0 {
+		case SecretFile:
+			setValues, errs := a.Command.parseSecretFiles(a.getEnvNames)
+			parseErrors = append(parseErrors, errs...)
+			settingsMap.addParsedArray(a.checkDeprecated(setValues))
+
+		case CliFlags:
+			settingsMap.addParsedArray(a.checkDeprecated(a.Command.getSetFlags()))
+
+		default:
+			if named, ok := a.keyValueSources[order]; ok {
+				setValues, errs := a.Command.parseKeyValueSource(a.context(), order, named)
+				parseErrors = append(parseErrors, errs...)
+				settingsMap.addParsedArray(a.checkDeprecated(setValues))
 				continue
 			}
-			setValues := a.Command.parseConfigValues(vars)
-			settingsMap.addParsedArray(setValues)
-
-		case TomlConfig:
-			vars := a.Command.getConfigVarsByType(TomlConfig)
+			vars := a.Command.getConfigVarsByType(order)
 			if len(vars) == 0 {
 				continue
 			}
-			setValues := a.Command.parseConfigValues(vars)
-			settingsMap.addParsedArray(setValues)
-
-		case CliFlags:
-			settingsMap.addParsedArray(a.Command.getSetFlags())
+			setValues, errs := a.Command.parseConfigValues(vars, a.getEnvNames)
+			parseErrors = append(parseErrors, errs...)
+			settingsMap.addParsedArray(a.checkDeprecated(setValues))
 		}
 	}
 	a.settingsMap = settingsMap
+	return parseErrors
 }
 
-func (a *App) applySettingsMap() {
+func (a *App) applySettingsMap() ParseErrors {
+	var parseErrors ParseErrors
 	commandMap := a.Command.GetExpandedActiveCommmands()
 	// loop through commands, ensure that the order of settings are constantly applied,
 	// instead of looping through MainMap, which is not a consistent order.
@@ -334,13 +738,58 @@ func (a *App) applySettingsMap() {
 			if setting == nil {
 				continue
 			}
-			activeSetting := setting[len(setting)-1]
-			currVariable := variableMap[activeSetting.VariableName]
+			currVariable := variableMap[name]
 			// special case, ignore a config variable.
 			if _, ok := currVariable.(*ConfigVariable); ok {
 				continue
 			}
-			currVariable.apply(activeSetting.Value)
+			for i, activeSetting := range getAppliedSettings(currVariable, setting) {
+				var err error
+				if i == 0 {
+					err = currVariable.apply(activeSetting.Value)
+				} else {
+					err = currVariable.(mergingVariable).merge(activeSetting.Value)
+				}
+				if err != nil {
+					parseErrors = append(parseErrors, &ParseError{
+						Source:   activeSetting.Source,
+						Command:  path,
+						Variable: name,
+						RawValue: fmt.Sprintf("%v", activeSetting.Value),
+						Err:      err,
+					})
+				}
+			}
+		}
+	}
+	return parseErrors
+}
+
+// Get the settings that are applied to a variable, in order.
+// Only the last setting is used, unless the variable merges sources, where every setting other than the defaults is used.
+// Keyed variables merge every setting including the defaults, and are reported per key instead.
+func getAppliedSettings(variable Variable, settings []*activeSetting) []*activeSetting {
+	if _, ok := variable.(keyedVariable); ok {
+		return settings
+	}
+	merging, ok := variable.(mergingVariable)
+	if !ok || !merging.mergesSources() {
+		return settings[len(settings)-1:]
+	}
+	var applied []*activeSetting
+	for _, setting := range settings {
+		if setting.Source != DefaultValue {
+			applied = append(applied, setting)
+		}
+	}
+	if len(applied) == 0 {
+		return settings[len(settings)-1:]
+	}
+	// a single source is used like any other value.
+	if len(applied) > 1 {
+		for _, setting := range applied {
+			setting.Merged = true
 		}
 	}
+	return applied
 }
@@ -0,0 +1,1621 @@
+package unpuzzled
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRunErrors struct {
+	Name       string
+	Command    *Command
+	Args       []string
+	EnvVars    []envVar
+	Validation func(*testing.T, error)
+}
+
+// RunE should never exit the process, every failure is returned as a typed error.
+func TestRunErrors(t *testing.T) {
+	var testString string
+	var testInt int
+	var testFloat float64
+	var testDuration time.Duration
+
+	tests := []testRunErrors{
+		testRunErrors{
+			Name: "Help requested.",
+			Command: &Command{
+				Name: "basic",
+			},
+			Args: []string{"path_to_exec", "--help"},
+			Validation: func(t *testing.T, err error) {
+				assert.Equal(t, ErrHelpRequested, err, "Help should return ErrHelpRequested.")
+			},
+		},
+		testRunErrors{
+			Name: "Missing required variables.",
+			Command: &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&StringVariable{
+						Name:        "test-value",
+						Destination: &testString,
+						Required:    true,
+					},
+				},
+			},
+			Args: []string{"path_to_exec"},
+			Validation: func(t *testing.T, err error) {
+				missingErr, ok := err.(*MissingRequiredError)
+				if assert.True(t, ok, "Error should be a *MissingRequiredError.") {
+					assert.Len(t, missingErr.Variables["basic"], 1, "One variable should be missing.")
+				}
+			},
+		},
+		testRunErrors{
+			Name: "Invalid environment variable.",
+			Command: &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&IntVariable{
+						Name:        "test-int",
+						Destination: &testInt,
+					},
+				},
+			},
+			Args:    []string{"path_to_exec"},
+			EnvVars: []envVar{envVar{"TEST_INT", "not-an-int"}},
+			Validation: func(t *testing.T, err error) {
+				parseErrors, ok := err.(ParseErrors)
+				if assert.True(t, ok, "Error should be ParseErrors.") && assert.Len(t, parseErrors, 1) {
+					assert.Equal(t, EnvironmentVariables, parseErrors[0].Source)
+					assert.Equal(t, "basic", parseErrors[0].Command)
+					assert.Equal(t, "test-int", parseErrors[0].Variable)
+					assert.Equal(t, "not-an-int", parseErrors[0].RawValue)
+				}
+			},
+		},
+		testRunErrors{
+			Name: "Invalid CLI flag.",
+			Command: &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&IntVariable{
+						Name:        "test-int",
+						Destination: &testInt,
+					},
+				},
+			},
+			Args: []string{"path_to_exec", "--test-int=abc"},
+			Validation: func(t *testing.T, err error) {
+				parseErrors, ok := err.(ParseErrors)
+				if assert.True(t, ok, "Error should be ParseErrors.") && assert.Len(t, parseErrors, 1) {
+					assert.Equal(t, CliFlags, parseErrors[0].Source)
+				}
+			},
+		},
+		testRunErrors{
+			Name: "Invalid values from every source are collected.",
+			Command: &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&IntVariable{
+						Name:        "test-int",
+						Destination: &testInt,
+					},
+					&Float64Variable{
+						Name:        "test-float",
+						Destination: &testFloat,
+					},
+					&DurationVariable{
+						Name:        "test-duration",
+						Destination: &testDuration,
+					},
+					&ConfigVariable{
+						StringVariable: &StringVariable{
+							Name: "config",
+						},
+						Type: TomlConfig,
+					},
+				},
+			},
+			Args: []string{"path_to_exec", "--config=./fixtures/invalid_values_test.toml", "--test-duration=abc"},
+			EnvVars: []envVar{
+				envVar{"TEST_INT", "not-an-int"},
+			},
+			Validation: func(t *testing.T, err error) {
+				parseErrors, ok := err.(ParseErrors)
+				if assert.True(t, ok, "Error should be ParseErrors.") && assert.Len(t, parseErrors, 3) {
+					assert.Equal(t, CliFlags, parseErrors[0].Source)
+					assert.Equal(t, EnvironmentVariables, parseErrors[1].Source)
+					assert.Equal(t, "test-int", parseErrors[1].Variable)
+					assert.Equal(t, TomlConfig, parseErrors[2].Source)
+					assert.Equal(t, "test-float", parseErrors[2].Variable)
+					assert.Equal(t, "not-a-float", parseErrors[2].RawValue)
+				}
+			},
+		},
+		testRunErrors{
+			Name: "Duplicate variable names.",
+			Command: &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&StringVariable{
+						Name:        "test-value",
+						Destination: &testString,
+					},
+					&StringVariable{
+						Name:        "test-value",
+						Destination: &testString,
+					},
+				},
+			},
+			Args: []string{"path_to_exec"},
+			Validation: func(t *testing.T, err error) {
+				assert.Equal(t, &DuplicateVariableError{Command: "basic", Variable: "test-value"}, err)
+			},
+		},
+		testRunErrors{
+			Name: "Invalid config file.",
+			Command: &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&ConfigVariable{
+						StringVariable: &StringVariable{
+							Name: "config",
+						},
+						Type: TomlConfig,
+					},
+				},
+			},
+			Args: []string{"path_to_exec", "--config=./fixtures/invalid_test.toml"},
+			Validation: func(t *testing.T, err error) {
+				configErr, ok := err.(*ConfigError)
+				if assert.True(t, ok, "Error should be a *ConfigError.") {
+					assert.Equal(t, "./fixtures/invalid_test.toml", configErr.Path)
+					assert.Equal(t, TomlConfig, configErr.Type)
+				}
+			},
+		},
+		testRunErrors{
+			Name: "Missing config file.",
+			Command: &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&ConfigVariable{
+						StringVariable: &StringVariable{
+							Name: "config",
+						},
+						Type: JsonConfig,
+					},
+				},
+			},
+			Args: []string{"path_to_exec", "--config=./fixtures/does_not_exist.json"},
+			Validation: func(t *testing.T, err error) {
+				_, ok := err.(*ConfigError)
+				assert.True(t, ok, "Error should be a *ConfigError.")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, envVar := range test.EnvVars {
+				os.Setenv(envVar.Key, envVar.Value)
+			}
+
+			app := NewApp()
+			app.Command = test.Command
+			app.Silent = true
+			test.Validation(t, app.RunE(test.Args))
+
+			for _, envVar := range test.EnvVars {
+				assert.NoError(t, os.Unsetenv(envVar.Key), "Should not error while unsetting the env var.")
+			}
+		})
+	}
+}
+
+// ActionE should receive the active commands, the remaining arguments, and the resolved settings.
+func TestActionContext(t *testing.T) {
+	var testString string
+	var testInt int
+	var testMap map[string]string
+	var testSlice []string
+	var actionContext *Context
+	actionErr := NewExitError(nil, 3)
+
+	app := NewApp()
+	app.Silent = true
+	app.Command = &Command{
+		Name: "main",
+		Variables: []Variable{
+			&StringVariable{
+				Name:        "test-value",
+				Destination: &testString,
+				Default:     "default-value",
+			},
+			&StringMapVariable{
+				Name:        "test-map",
+				Destination: &testMap,
+			},
+			&StringSliceVariable{
+				Name:          "test-slice",
+				Destination:   &testSlice,
+				AppendSources: true,
+			},
+		},
+		Subcommands: []*Command{
+			&Command{
+				Name: "nested",
+				Variables: []Variable{
+					&IntVariable{
+						Name:        "test-int",
+						Destination: &testInt,
+					},
+				},
+				ActionE: func(ctx *Context) error {
+					actionContext = ctx
+					return actionErr
+				},
+			},
+		},
+	}
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	os.Setenv("TEST_MAP", "a=1")
+	defer os.Unsetenv("TEST_MAP")
+	os.Setenv("TEST_SLICE", "x")
+	defer os.Unsetenv("TEST_SLICE")
+	err := app.RunContext(ctx, []string{"path_to_exec", "--test-map=b=2", "--test-slice=y", "nested", "--test-int=5", "first", "second"})
+	assert.Equal(t, actionErr, err, "The ActionE error should be returned.")
+	assert.Equal(t, 3, err.(ExitCoder).ExitCode())
+
+	if assert.NotNil(t, actionContext, "ActionE should be called.") {
+		assert.Equal(t, "value", actionContext.Context().Value(key{}))
+		assert.Equal(t, []string{"first", "second"}, actionContext.Args)
+		assert.Equal(t, "main.nested", actionContext.Command().GetExpandedName())
+		assert.Len(t, actionContext.Commands, 2)
+
+		value, source, ok := actionContext.Lookup("test-int")
+		assert.True(t, ok)
+		assert.Equal(t, 5, value)
+		assert.Equal(t, CliFlags, source)
+
+		value, source, ok = actionContext.Lookup("test-value")
+		assert.True(t, ok)
+		assert.Equal(t, "default-value", value)
+		assert.Equal(t, DefaultValue, source)
+
+		value, source, ok = actionContext.Lookup("test-map")
+		assert.True(t, ok)
+		assert.Equal(t, map[string]string{"a": "1", "b": "2"}, value, "Map values should be merged from every source.")
+		assert.Equal(t, CliFlags, source)
+
+		value, source, ok = actionContext.Lookup("test-slice")
+		assert.True(t, ok)
+		assert.Equal(t, []string{"x", "y"}, value, "Appended slices should include every source.")
+		assert.Equal(t, CliFlags, source)
+
+		_, _, ok = actionContext.Lookup("not-a-variable")
+		assert.False(t, ok)
+	}
+}
+
+// Running the app again should only use the new arguments.
+func TestRunTwice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unpuzzled")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.toml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("[main]\nnames=[\"config\"]\n"), 0644))
+
+	var names []string
+	var port int
+	var calls []string
+	sub := &Command{
+		Name: "sub",
+		Action: func() {
+			calls = append(calls, "sub")
+		},
+	}
+	app := NewApp()
+	app.Silent = true
+	app.Command = &Command{
+		Name: "main",
+		Variables: []Variable{
+			&ConfigVariable{
+				StringVariable: &StringVariable{
+					Name:    "config",
+					Default: path,
+				},
+				Type: TomlConfig,
+			},
+			&StringSliceVariable{
+				Name:          "names",
+				Destination:   &names,
+				AppendSources: true,
+			},
+			&IntVariable{
+				Name:        "port",
+				Destination: &port,
+			},
+		},
+		Subcommands: []*Command{sub},
+		Action: func() {
+			calls = append(calls, "main")
+		},
+	}
+
+	assert.NoError(t, app.RunE([]string{"path_to_exec", "--port=1", "--names=flag", "sub"}))
+	assert.Equal(t, []string{"config", "flag"}, names)
+	assert.Equal(t, 1, port)
+
+	assert.NoError(t, app.RunE([]string{"path_to_exec"}))
+	assert.Equal(t, []string{"sub", "main"}, calls, "The subcommand should only be active in the first run.")
+	assert.False(t, sub.Active)
+	assert.Len(t, app.Command.configVars, 1)
+	assert.Equal(t, []string{"config"}, names, "Settings from the first run should not be kept.")
+	assert.Equal(t, 0, port)
+}
+
+type testHooks struct {
+	Name          string
+	FailBefore    string
+	FailAction    bool
+	ExpectedCalls []string
+	ExpectedErr   string
+}
+
+// BeforeFunc should run top-down, AfterFunc bottom-up, and AfterFunc should run even when the action fails.
+func TestBeforeAfterHooks(t *testing.T) {
+	tests := []testHooks{
+		testHooks{
+			Name:          "Successful run.",
+			ExpectedCalls: []string{"before main", "before main.nested", "action", "after main.nested", "after main"},
+		},
+		testHooks{
+			Name:          "Failed action still runs after hooks.",
+			FailAction:    true,
+			ExpectedCalls: []string{"before main", "before main.nested", "action", "after main.nested", "after main"},
+			ExpectedErr:   "action",
+		},
+		testHooks{
+			Name:          "Failed before hook stops the run.",
+			FailBefore:    "main.nested",
+			ExpectedCalls: []string{"before main", "before main.nested", "after main"},
+			ExpectedErr:   "before main.nested",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var calls []string
+			before := func(c *Command) error {
+				calls = append(calls, "before "+c.GetExpandedName())
+				if c.GetExpandedName() == test.FailBefore {
+					return errors.New("before " + c.GetExpandedName())
+				}
+				return nil
+			}
+			after := func(c *Command) error {
+				calls = append(calls, "after "+c.GetExpandedName())
+				return nil
+			}
+
+			app := NewApp()
+			app.Silent = true
+			app.Command = &Command{
+				Name:       "main",
+				BeforeFunc: before,
+				AfterFunc:  after,
+				Subcommands: []*Command{
+					&Command{
+						Name:       "nested",
+						BeforeFunc: before,
+						AfterFunc:  after,
+						ActionE: func(ctx *Context) error {
+							calls = append(calls, "action")
+							if test.FailAction {
+								return errors.New("action")
+							}
+							return nil
+						},
+					},
+				},
+			}
+			err := app.RunE([]string{"path_to_exec", "nested"})
+			assert.Equal(t, test.ExpectedCalls, calls, "Hooks should be called in order.")
+			if test.ExpectedErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equal(t, test.ExpectedErr, err.Error())
+			}
+		})
+	}
+}
+
+const testPropertiesConfig = CustomConfig
+
+// key=value config loader, used to test custom config loaders.
+type testPropertiesLoader struct{}
+
+func (p *testPropertiesLoader) Name() string {
+	return "Properties Config"
+}
+
+func (p *testPropertiesLoader) Load(r io.Reader) (ConfigSource, error) {
+	values := testPropertiesSource{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("Missing = in line.")
+		}
+		values[parts[0]] = parts[1]
+	}
+	return values, scanner.Err()
+}
+
+type testPropertiesSource map[string]string
+
+func (p testPropertiesSource) Lookup(path string) (interface{}, error) {
+	if value, ok := p[path]; ok {
+		return value, nil
+	}
+	return nil, nil
+}
+
+func TestConfigLoader(t *testing.T) {
+	var testString string
+	var nestedInt int
+
+	app := NewApp()
+	app.Silent = true
+	app.RegisterConfigLoader(testPropertiesConfig, &testPropertiesLoader{})
+	app.Command = &Command{
+		Name: "basic",
+		Variables: []Variable{
+			&StringVariable{
+				Name:        "test-string",
+				Destination: &testString,
+			},
+			&ConfigVariable{
+				StringVariable: &StringVariable{
+					Name: "config",
+				},
+				Type: testPropertiesConfig,
+			},
+		},
+		Subcommands: []*Command{
+			&Command{
+				Name: "nested",
+				Variables: []Variable{
+					&IntVariable{
+						Name:        "test-int",
+						Destination: &nestedInt,
+					},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, []ParsingType{DotEnvConfig, EnvironmentVariables, SecretFile, JsonConfig, TomlConfig, YamlConfig, ConfigDirectory, testPropertiesConfig, CliFlags}, app.ParsingOrder, "Custom loaders should be parsed before cli flags.")
+	assert.Equal(t, "Properties Config", app.sourceName(testPropertiesConfig))
+	_, ok := ParsingTypeStringMap[testPropertiesConfig]
+	assert.False(t, ok, "Loader names should not be added to the global map.")
+	assert.Empty(t, NewApp().sourceName(testPropertiesConfig), "Loader names should not leak into other apps.")
+
+	assert.NoError(t, app.RunE([]string{"path_to_exec", "--config=./fixtures/custom_test.properties", "nested"}))
+	assert.Equal(t, "from properties", testString)
+	assert.Equal(t, 7, nestedInt)
+
+	settings := app.settingsMap.MainMap["basic"]["test-string"]
+	if assert.Len(t, settings, 1) {
+		assert.Equal(t, testPropertiesConfig, settings[0].Source)
+		assert.Equal(t, "./fixtures/custom_test.properties", settings[0].SettingName, "The setting name should be the loaded file.")
+	}
+
+	app.RegisterConfigLoader(testPropertiesConfig, &testPropertiesLoader{})
+	assert.Len(t, app.ParsingOrder, 9, "Registering a loader twice should not change the parsing order.")
+
+	err := app.RunE([]string{"path_to_exec", "--config=./fixtures/missing.properties"})
+	if configErr, ok := err.(*ConfigError); assert.True(t, ok, "Error should be a *ConfigError.") {
+		assert.Contains(t, configErr.Error(), "Failed to load Properties Config")
+	}
+}
+
+func TestUnknownConfigType(t *testing.T) {
+	app := NewApp()
+	app.Silent = true
+	app.Command = &Command{
+		Name: "basic",
+		Variables: []Variable{
+			&ConfigVariable{
+				StringVariable: &StringVariable{
+					Name: "config",
+				},
+				Type: CustomConfig + 1,
+			},
+		},
+	}
+	err := app.RunE([]string{"path_to_exec", "--config=./fixtures/custom_test.properties"})
+	configErr, ok := err.(*ConfigError)
+	if assert.True(t, ok, "Error should be a *ConfigError.") {
+		assert.Equal(t, ErrConfigTypeUnknown, configErr.Err)
+	}
+}
+
+type testDeprecated struct {
+	Name       string
+	Args       []string
+	EnvVars    []envVar
+	Validation func(*testing.T, *App, error)
+}
+
+func TestDeprecated(t *testing.T) {
+	var port int
+	var debug bool
+
+	tests := []testDeprecated{
+		testDeprecated{
+			Name: "Not set.",
+			Validation: func(t *testing.T, app *App, err error) {
+				assert.NoError(t, err)
+				assert.Empty(t, app.Warnings())
+			},
+		},
+		testDeprecated{
+			Name: "Deprecated variable.",
+			Args: []string{"--debug"},
+			Validation: func(t *testing.T, app *App, err error) {
+				assert.NoError(t, err)
+				assert.True(t, debug, "Deprecated variables should still be set.")
+				assert.Equal(t, []*Warning{
+					&Warning{
+						Command:  "basic",
+						Variable: "debug",
+						Source:   CliFlags,
+						Message:  "Use --log-level instead.",
+					},
+				}, app.Warnings())
+			},
+		},
+		testDeprecated{
+			Name: "Forwarded to the replacement.",
+			EnvVars: []envVar{
+				envVar{"LISTEN", "5"},
+			},
+			Validation: func(t *testing.T, app *App, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, 5, port)
+				if assert.Len(t, app.Warnings(), 1) {
+					assert.Equal(t, "port", app.Warnings()[0].ReplacedBy)
+					assert.Equal(t, "basic.listen set from Environment is deprecated (value forwarded to port)", app.Warnings()[0].String())
+				}
+				settings := app.settingsMap.MainMap["basic"]["port"]
+				if assert.Len(t, settings, 1) {
+					assert.Equal(t, "listen", settings[0].Alias, "The forwarded value should name the deprecated variable.")
+				}
+			},
+		},
+		testDeprecated{
+			Name: "Replacement set from a later source.",
+			Args: []string{"--port=6"},
+			EnvVars: []envVar{
+				envVar{"LISTEN", "5"},
+			},
+			Validation: func(t *testing.T, app *App, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, 6, port)
+			},
+		},
+		testDeprecated{
+			Name: "Replacement set from the same source.",
+			Args: []string{"--listen=1", "--port=2"},
+			Validation: func(t *testing.T, app *App, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, 2, port, "The replacement's own value should win over the forwarded value.")
+				assert.Len(t, app.Warnings(), 1)
+			},
+		},
+		testDeprecated{
+			Name: "Deprecated alias.",
+			Args: []string{"--old-port=3"},
+			Validation: func(t *testing.T, app *App, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, 3, port)
+				assert.Equal(t, []*Warning{
+					&Warning{
+						Command:  "basic",
+						Variable: "port",
+						Alias:    "old-port",
+						Source:   CliFlags,
+						Message:  "Use --port instead.",
+					},
+				}, app.Warnings())
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			port, debug = 0, false
+			for _, envVar := range test.EnvVars {
+				os.Setenv(envVar.Key, envVar.Value)
+			}
+
+			app := NewApp()
+			app.Silent = true
+			app.Command = &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&IntVariable{
+						Name:        "port",
+						Destination: &port,
+						VariableOptions: VariableOptions{
+							Aliases:           []string{"old-port"},
+							DeprecatedAliases: map[string]string{"old-port": "Use --port instead."},
+						},
+					},
+					&IntVariable{
+						Name: "listen",
+						VariableOptions: VariableOptions{
+							ReplacedBy: "port",
+						},
+					},
+					&BoolVariable{
+						Name:        "debug",
+						Destination: &debug,
+						VariableOptions: VariableOptions{
+							Deprecated: "Use --log-level instead.",
+						},
+					},
+				},
+			}
+			err := app.RunE(append([]string{"path_to_exec"}, test.Args...))
+			test.Validation(t, app, err)
+
+			for _, envVar := range test.EnvVars {
+				assert.NoError(t, os.Unsetenv(envVar.Key), "Should not error while unsetting the env var.")
+			}
+		})
+	}
+
+	app := NewApp()
+	app.Silent = true
+	app.Command = &Command{
+		Name: "basic",
+		Variables: []Variable{
+			&IntVariable{
+				Name: "listen",
+				VariableOptions: VariableOptions{
+					ReplacedBy: "missing",
+				},
+			},
+		},
+	}
+	_, ok := app.RunE([]string{"path_to_exec"}).(*UnknownReplacementError)
+	assert.True(t, ok, "Error should be an *UnknownReplacementError.")
+
+	app.Command.Variables = append(app.Command.Variables, &StringVariable{Name: "missing"})
+	_, ok = app.RunE([]string{"path_to_exec"}).(*ReplacementTypeError)
+	assert.True(t, ok, "Error should be a *ReplacementTypeError.")
+}
+
+func TestValidation(t *testing.T) {
+	var port int
+	var level string
+	var timeout time.Duration
+	var hosts []string
+
+	variables := func() []Variable {
+		return []Variable{
+			&IntVariable{
+				Name:        "port",
+				Default:     8080,
+				Destination: &port,
+				VariableOptions: VariableOptions{
+					Constraints: []Constraint{Min(1), Max(65535)},
+				},
+			},
+			&StringVariable{
+				Name:        "level",
+				Destination: &level,
+				VariableOptions: VariableOptions{
+					Constraints: []Constraint{OneOf("debug", "info")},
+				},
+			},
+			&DurationVariable{
+				Name:        "timeout",
+				Destination: &timeout,
+				VariableOptions: VariableOptions{
+					Constraints: []Constraint{Max(float64(time.Minute))},
+				},
+			},
+			&StringSliceVariable{
+				Name:        "hosts",
+				Destination: &hosts,
+				VariableOptions: VariableOptions{
+					Constraints: []Constraint{NonEmpty(), Pattern(`^[a-z.]+$`)},
+					Validate: func(value interface{}) error {
+						if len(value.([]string)) > 2 {
+							return errors.New("Too many hosts.")
+						}
+						return nil
+					},
+				},
+			},
+		}
+	}
+
+	tests := []testRunErrors{
+		testRunErrors{
+			Name: "Valid values.",
+			Args: []string{"--port=80", "--level=info", "--timeout=5s", "--hosts=a.com,b.com"},
+			Validation: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, 80, port)
+			},
+		},
+		testRunErrors{
+			Name: "Unset variables are not validated.",
+			Validation: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, "", level)
+			},
+		},
+		testRunErrors{
+			Name: "Numeric limits.",
+			Args: []string{"--port=0", "--timeout=2m"},
+			Validation: func(t *testing.T, err error) {
+				validationErrors, ok := err.(ValidationErrors)
+				if assert.True(t, ok, "Should return ValidationErrors.") && assert.Len(t, validationErrors, 2) {
+					assert.Equal(t, "port", validationErrors[0].Variable)
+					assert.Equal(t, 0, validationErrors[0].Value)
+					assert.Equal(t, CliFlags, validationErrors[0].Source)
+					assert.EqualError(t, validationErrors[0].Err, "Must be at least 1.")
+					assert.EqualError(t, validationErrors[1].Err, "Must be at most 1m0s.")
+				}
+			},
+		},
+		testRunErrors{
+			Name: "Env value not allowed.",
+			EnvVars: []envVar{
+				envVar{"LEVEL", "trace"},
+			},
+			Validation: func(t *testing.T, err error) {
+				validationErrors, ok := err.(ValidationErrors)
+				if assert.True(t, ok, "Should return ValidationErrors.") && assert.Len(t, validationErrors, 1) {
+					assert.Equal(t, EnvironmentVariables, validationErrors[0].Source)
+					assert.EqualError(t, validationErrors[0], "Invalid value for basic.level from Environment (trace): Must be one of: debug, info.")
+				}
+			},
+		},
+		testRunErrors{
+			Name: "Slice elements and validate func.",
+			Args: []string{"--hosts=a.com,B.com,c.com"},
+			Validation: func(t *testing.T, err error) {
+				validationErrors, ok := err.(ValidationErrors)
+				if assert.True(t, ok, "Should return ValidationErrors.") && assert.Len(t, validationErrors, 2) {
+					assert.EqualError(t, validationErrors[0].Err, "Must match ^[a-z.]+$.")
+					assert.EqualError(t, validationErrors[1].Err, "Too many hosts.")
+				}
+			},
+		},
+		testRunErrors{
+			Name: "Empty slice.",
+			Args: []string{"--hosts="},
+			Validation: func(t *testing.T, err error) {
+				validationErrors, ok := err.(ValidationErrors)
+				if assert.True(t, ok, "Should return ValidationErrors.") && assert.Len(t, validationErrors, 1) {
+					assert.EqualError(t, validationErrors[0].Err, "Must not be empty.")
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			port, level, timeout, hosts = 0, "", 0, nil
+			for _, envVar := range test.EnvVars {
+				os.Setenv(envVar.Key, envVar.Value)
+			}
+
+			app := NewApp()
+			app.Silent = true
+			app.Command = &Command{
+				Name:      "basic",
+				Variables: variables(),
+			}
+			test.Validation(t, app.RunE(append([]string{"path_to_exec"}, test.Args...)))
+
+			for _, envVar := range test.EnvVars {
+				assert.NoError(t, os.Unsetenv(envVar.Key), "Should not error while unsetting the env var.")
+			}
+		})
+	}
+
+	assert.Equal(t, "Listen port (min 1, max 65535)", getHelpDescription(&IntVariable{
+		Name:        "port",
+		Description: "Listen port",
+		VariableOptions: VariableOptions{
+			Constraints: []Constraint{Min(1), Max(65535)},
+		},
+	}))
+	assert.Equal(t, "Timeout (min 1s, max 1h0m0s)", getHelpDescription(&DurationVariable{
+		Name:        "timeout",
+		Description: "Timeout",
+		VariableOptions: VariableOptions{
+			Constraints: []Constraint{Min(float64(time.Second)), Max(float64(time.Hour))},
+		},
+	}), "Duration limits should be shown as durations.")
+	assert.Equal(t, "(max 1000000)", getHelpDescription(&IntSliceVariable{
+		Name: "sizes",
+		VariableOptions: VariableOptions{
+			Constraints: []Constraint{Max(1000000)},
+		},
+	}))
+	assert.Equal(t, "(one of: debug, info)", getHelpDescription(&StringVariable{
+		Name: "level",
+		VariableOptions: VariableOptions{
+			Constraints: []Constraint{OneOf("debug", "info")},
+		},
+	}))
+
+	var genericLevel testLevel
+	for _, variable := range []Variable{
+		&StringVariable{Name: "name", Destination: &level, VariableOptions: VariableOptions{Constraints: []Constraint{Min(1)}}},
+		&GenericVariable{Name: "level", Destination: &genericLevel, VariableOptions: VariableOptions{Constraints: []Constraint{Max(3)}}},
+		&IntVariable{Name: "port", Destination: &port, VariableOptions: VariableOptions{Constraints: []Constraint{NonEmpty()}}},
+	} {
+		app := NewApp()
+		app.Silent = true
+		app.Command = &Command{
+			Name:      "basic",
+			Variables: []Variable{variable},
+		}
+		_, ok := app.RunE([]string{"path_to_exec"}).(*ConstraintTypeError)
+		assert.True(t, ok, "Constraints that can't check the variable's type should be an error.")
+	}
+}
+
+func TestConstraintGroups(t *testing.T) {
+	var token, password, cert, key string
+	var tls bool
+
+	tests := []testRunErrors{
+		testRunErrors{
+			Name: "Valid values.",
+			Args: []string{"--token=a", "--cert=c", "--key=k", "--tls"},
+			Validation: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		testRunErrors{
+			Name: "Mutually exclusive.",
+			Args: []string{"--password=b"},
+			EnvVars: []envVar{
+				envVar{"TOKEN", "a"},
+			},
+			Validation: func(t *testing.T, err error) {
+				groupErrors, ok := err.(ConstraintGroupErrors)
+				if assert.True(t, ok, "Should return ConstraintGroupErrors.") && assert.Len(t, groupErrors, 1) {
+					assert.Equal(t, ExclusiveGroup, groupErrors[0].Group.Type)
+					assert.Equal(t, []*GroupValue{
+						&GroupValue{Variable: "token", Value: "a", Source: EnvironmentVariables, SettingName: "TOKEN"},
+						&GroupValue{Variable: "password", Value: "b", Source: CliFlags},
+					}, groupErrors[0].Set)
+					assert.EqualError(t, groupErrors[0], "basic: Only one of token, password can be set. Set: token from Environment (TOKEN), password from CLI Flag.")
+				}
+			},
+		},
+		testRunErrors{
+			Name: "None of at least one.",
+			Validation: func(t *testing.T, err error) {
+				groupErrors, ok := err.(ConstraintGroupErrors)
+				if assert.True(t, ok, "Should return ConstraintGroupErrors.") && assert.Len(t, groupErrors, 1) {
+					assert.Equal(t, AtLeastOneGroup, groupErrors[0].Group.Type)
+					assert.Equal(t, []string{"token", "password"}, groupErrors[0].Missing)
+				}
+			},
+		},
+		testRunErrors{
+			Name: "Required together and required if.",
+			Args: []string{"--token=a", "--cert=c", "--tls"},
+			Validation: func(t *testing.T, err error) {
+				groupErrors, ok := err.(ConstraintGroupErrors)
+				if assert.True(t, ok, "Should return ConstraintGroupErrors.") && assert.Len(t, groupErrors, 2) {
+					assert.Equal(t, TogetherGroup, groupErrors[0].Group.Type)
+					assert.Equal(t, []string{"key"}, groupErrors[0].Missing)
+					assert.Equal(t, RequiredIfGroup, groupErrors[1].Group.Type)
+					assert.Equal(t, []string{"key"}, groupErrors[1].Missing)
+					assert.Equal(t, "key required when tls is set.", groupErrors[1].Group.String())
+				}
+			},
+		},
+		testRunErrors{
+			Name: "Defaults are ignored.",
+			Args: []string{"--password=b", "--cert=c"},
+			Validation: func(t *testing.T, err error) {
+				groupErrors, ok := err.(ConstraintGroupErrors)
+				if assert.True(t, ok, "Should return ConstraintGroupErrors.") && assert.Len(t, groupErrors, 1) {
+					assert.Equal(t, TogetherGroup, groupErrors[0].Group.Type, "The key default should not count as set.")
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, envVar := range test.EnvVars {
+				os.Setenv(envVar.Key, envVar.Value)
+			}
+
+			app := NewApp()
+			app.Silent = true
+			app.Command = &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&StringVariable{Name: "token", Destination: &token},
+					&StringVariable{Name: "password", Destination: &password},
+					&StringVariable{Name: "cert", Destination: &cert},
+					&StringVariable{Name: "key", Default: "default.key", Destination: &key},
+					&BoolVariable{Name: "tls", Destination: &tls},
+				},
+				ConstraintGroups: []*ConstraintGroup{
+					MutuallyExclusive("token", "password"),
+					AtLeastOne("token", "password"),
+					RequiredTogether("cert", "key"),
+					RequiredIf("tls", "key"),
+				},
+			}
+			test.Validation(t, app.RunE(append([]string{"path_to_exec"}, test.Args...)))
+
+			for _, envVar := range test.EnvVars {
+				assert.NoError(t, os.Unsetenv(envVar.Key), "Should not error while unsetting the env var.")
+			}
+		})
+	}
+
+	app := NewApp()
+	app.Silent = true
+	app.Command = &Command{
+		Name:             "basic",
+		ConstraintGroups: []*ConstraintGroup{RequiredTogether("cert", "missing")},
+	}
+	err := app.RunE([]string{"path_to_exec"})
+	assert.Equal(t, &UnknownGroupVariableError{Command: "basic", Variable: "cert"}, err)
+}
+
+// Run fn with os.Stdout redirected, returning everything written.
+func captureStdout(t *testing.T, fn func()) string {
+	reader, writer, err := os.Pipe()
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	fn()
+	os.Stdout = stdout
+	writer.Close()
+	buffer := new(bytes.Buffer)
+	io.Copy(buffer, reader)
+	return buffer.String()
+}
+
+func TestSensitive(t *testing.T) {
+	var password, user string
+	var pin int
+
+	newApp := func() *App {
+		app := NewApp()
+		app.RemoveColor = true
+		app.Command = &Command{
+			Name: "basic",
+			Variables: []Variable{
+				&SecretStringVariable{&StringVariable{
+					Name:        "password",
+					Default:     "default-secret",
+					Destination: &password,
+				}},
+				&IntVariable{
+					Name:        "pin",
+					Destination: &pin,
+					VariableOptions: VariableOptions{
+						Aliases:     []string{"code"},
+						Sensitive:   true,
+						Constraints: []Constraint{Max(9999)},
+					},
+				},
+				&StringVariable{
+					Name:        "user",
+					Destination: &user,
+				},
+			},
+		}
+		return app
+	}
+
+	os.Setenv("PASSWORD", "env-secret")
+	defer os.Unsetenv("PASSWORD")
+
+	for _, tableOutput := range []bool{false, true} {
+		app := newApp()
+		app.OverridesOutputInTable = tableOutput
+		var err error
+		output := captureStdout(t, func() {
+			err = app.RunE([]string{"path_to_exec", "--password=flag-secret", "--pin=1234", "--user=admin"})
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "flag-secret", password, "Sensitive values should still be set.")
+		assert.Equal(t, 1234, pin)
+		assert.Contains(t, output, "admin")
+		assert.Contains(t, output, maskedValue)
+		assert.Contains(t, output, "PASSWORD", "The source should still be shown.")
+		for _, secret := range []string{"default-secret", "env-secret", "flag-secret", "1234"} {
+			assert.NotContains(t, output, secret, "Sensitive values should be masked.")
+		}
+
+		encoded, err := json.Marshal(app.settingsMap)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(encoded), "secret")
+		assert.Contains(t, string(encoded), `"sensitive":true`)
+	}
+
+	app := newApp()
+	app.Silent = true
+	err := app.RunE([]string{"path_to_exec", "--pin=12345"})
+	if validationErrors, ok := err.(ValidationErrors); assert.True(t, ok, "Should return ValidationErrors.") {
+		assert.Equal(t, maskedValue, validationErrors[0].Value)
+	}
+
+	os.Setenv("PIN", "12ab")
+	app = newApp()
+	app.Silent = true
+	err = app.RunE([]string{"path_to_exec"})
+	os.Unsetenv("PIN")
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "12ab", "Parse errors should mask the raw value.")
+
+	for _, flag := range []string{"--pin=abc12", "--code=abc12"} {
+		app = newApp()
+		app.Silent = true
+		err = app.RunE([]string{"path_to_exec", flag})
+		if parseErrors, ok := err.(ParseErrors); assert.True(t, ok, "Should return ParseErrors.") {
+			assert.Equal(t, "pin", parseErrors[0].Variable, "Flag errors should be matched to the variable.")
+			assert.Equal(t, maskedValue, parseErrors[0].RawValue)
+			assert.NotContains(t, err.Error(), "abc12", "Flag parse errors should mask the raw value.")
+		}
+	}
+
+	output := captureStdout(t, func() {
+		newApp().RunE([]string{"path_to_exec", "--help"})
+	})
+	assert.NotContains(t, output, "default-secret", "Help defaults should be masked.")
+	assert.Contains(t, output, maskedValue)
+}
+
+func TestSecretFiles(t *testing.T) {
+	var password string
+	var port int
+
+	newApp := func() *App {
+		app := NewApp()
+		app.RemoveColor = true
+		app.Command = &Command{
+			Name: "basic",
+			Variables: []Variable{
+				&StringVariable{
+					Name:        "db-password",
+					Destination: &password,
+					VariableOptions: VariableOptions{
+						Aliases:  []string{"password"},
+						FromFile: "./fixtures/missing_secret.txt",
+					},
+				},
+				&IntVariable{
+					Name:        "port",
+					Destination: &port,
+					VariableOptions: VariableOptions{
+						FromFile: "./fixtures/secret_int_test.txt",
+					},
+				},
+			},
+		}
+		return app
+	}
+
+	os.Setenv("PASSWORD_FILE", "./fixtures/secret_test.txt")
+	os.Setenv("DB_PASSWORD", "from-env")
+	app := newApp()
+	var err error
+	output := captureStdout(t, func() {
+		err = app.RunE([]string{"path_to_exec"})
+	})
+	os.Unsetenv("DB_PASSWORD")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret-from-file", password, "The secret file should override the environment, without the trailing newline.")
+	assert.Equal(t, 42, port, "FromFile should be parsed like an environment variable.")
+
+	settings := app.settingsMap.MainMap["basic"]["db-password"]
+	if assert.Len(t, settings, 2) {
+		assert.Equal(t, SecretFile, settings[1].Source)
+		assert.Equal(t, "./fixtures/secret_test.txt", settings[1].SettingName)
+		assert.Equal(t, "password", settings[1].Alias)
+		assert.True(t, settings[1].Sensitive, "Secret file values should always be masked.")
+	}
+	assert.Contains(t, output, "Secret File (./fixtures/secret_test.txt)")
+	assert.NotContains(t, output, "s3cret-from-file")
+
+	os.Setenv("PASSWORD_FILE", "./fixtures/missing_secret.txt")
+	app = newApp()
+	app.Silent = true
+	err = app.RunE([]string{"path_to_exec"})
+	os.Unsetenv("PASSWORD_FILE")
+	if parseErrors, ok := err.(ParseErrors); assert.True(t, ok, "A missing _FILE path should be an error.") && assert.Len(t, parseErrors, 1) {
+		assert.Equal(t, SecretFile, parseErrors[0].Source)
+		assert.Equal(t, "db-password", parseErrors[0].Variable)
+	}
+
+	app = newApp()
+	app.Silent = true
+	assert.NoError(t, app.RunE([]string{"path_to_exec", "--port=1"}), "A missing FromFile should be skipped.")
+	assert.Equal(t, 1, port)
+}
+
+// Only implements Get, to test sources without List.
+type testGetOnlySource struct {
+	source KeyValueSource
+	err    error
+}
+
+func (t *testGetOnlySource) Get(ctx context.Context, key string) (string, bool, error) {
+	if t.err != nil {
+		return "", false, t.err
+	}
+	return t.source.Get(ctx, key)
+}
+
+func TestKeyValueSource(t *testing.T) {
+	const (
+		testMemorySource ParsingType = CustomConfig + 10 + iota
+		testFileSource
+	)
+	var testString string
+	var testInt int
+
+	newApp := func() *App {
+		app := NewApp()
+		app.Silent = true
+		app.Command = &Command{
+			Name: "basic",
+			Variables: []Variable{
+				&StringVariable{
+					Name:        "test-string",
+					Destination: &testString,
+					VariableOptions: VariableOptions{
+						Aliases: []string{"old-string"},
+					},
+				},
+			},
+			Subcommands: []*Command{
+				&Command{
+					Name: "nested",
+					Variables: []Variable{
+						&IntVariable{
+							Name:        "test-int",
+							Destination: &testInt,
+						},
+					},
+				},
+			},
+		}
+		return app
+	}
+
+	memory := NewMemoryKeyValueSource(map[string]string{
+		"basic.old-string":      "from-memory",
+		"basic.nested.test-int": "5",
+	})
+	for _, source := range []KeyValueSource{memory, &testGetOnlySource{source: memory}} {
+		testString, testInt = "", 0
+		app := newApp()
+		app.RegisterKeyValueSource(testMemorySource, "Memory Store", source)
+		assert.Equal(t, []ParsingType{DotEnvConfig, EnvironmentVariables, SecretFile, JsonConfig, TomlConfig, YamlConfig, ConfigDirectory, testMemorySource, CliFlags}, app.ParsingOrder, "Key-value sources should be parsed before cli flags.")
+
+		assert.NoError(t, app.RunContext(context.Background(), []string{"path_to_exec", "nested"}))
+		assert.Equal(t, "from-memory", testString)
+		assert.Equal(t, 5, testInt)
+		settings := app.settingsMap.MainMap["basic"]["test-string"]
+		if assert.Len(t, settings, 1) {
+			assert.Equal(t, testMemorySource, settings[0].Source)
+			assert.Equal(t, "Memory Store", settings[0].SettingName, "The setting name should be the store name.")
+			assert.Equal(t, "old-string", settings[0].Alias)
+		}
+	}
+
+	memory.Set("basic.nested.test-int", "abc")
+	app := newApp()
+	app.RegisterKeyValueSource(testMemorySource, "Memory Store", memory)
+	err := app.RunE([]string{"path_to_exec", "nested"})
+	if parseErrors, ok := err.(ParseErrors); assert.True(t, ok, "Invalid values should be parse errors.") && assert.Len(t, parseErrors, 1) {
+		assert.Equal(t, testMemorySource, parseErrors[0].Source)
+		assert.Equal(t, "abc", parseErrors[0].RawValue)
+		assert.Contains(t, parseErrors[0].Error(), "from Memory Store", "Errors should use the name of the source.")
+	}
+	_, ok := ParsingTypeStringMap[testMemorySource]
+	assert.False(t, ok, "Source names should not be added to the global map.")
+	assert.Empty(t, NewApp().sourceName(testMemorySource), "Source names should not leak into other apps.")
+
+	app = newApp()
+	app.RegisterKeyValueSource(testMemorySource, "Memory Store", &testGetOnlySource{err: errors.New("connection refused")})
+	err = app.RunE([]string{"path_to_exec"})
+	if parseErrors, ok := err.(ParseErrors); assert.True(t, ok, "Store errors should be parse errors.") && assert.Len(t, parseErrors, 1) {
+		assert.EqualError(t, parseErrors[0].Err, "connection refused")
+	}
+
+	testString, testInt = "", 0
+	app = newApp()
+	app.RegisterKeyValueSource(testFileSource, "File Store", &FileKeyValueSource{Dir: "./fixtures/kv"})
+	assert.NoError(t, app.RunE([]string{"path_to_exec", "nested", "--test-int=10"}))
+	assert.Equal(t, "from-file", testString)
+	assert.Equal(t, 10, testInt, "Cli flags should override the store.")
+
+	values, err := (&FileKeyValueSource{Dir: "./fixtures/kv"}).List(context.Background(), "basic.")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"basic.test-string": "from-file", "basic.nested.test-int": "9"}, values)
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unpuzzled")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.toml")
+	writeConfig := func(contents string) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+	writeConfig("[basic]\nport=1\nname=\"first\"\n")
+
+	var port int
+	var name, token string
+	changes := make(chan []*Change, 10)
+	app := NewApp()
+	app.Silent = true
+	app.OnChange = func(c []*Change) {
+		changes <- c
+	}
+	app.Command = &Command{
+		Name: "basic",
+		Variables: []Variable{
+			&ConfigVariable{
+				StringVariable: &StringVariable{
+					Name:    "config",
+					Default: path,
+				},
+				Type: TomlConfig,
+			},
+			&IntVariable{
+				Name:        "port",
+				Destination: &port,
+				VariableOptions: VariableOptions{
+					Reloadable:  true,
+					Constraints: []Constraint{Min(1)},
+				},
+			},
+			&StringVariable{
+				Name:        "name",
+				Destination: &name,
+			},
+			&StringVariable{
+				Name:        "token",
+				Destination: &token,
+				VariableOptions: VariableOptions{
+					Reloadable: true,
+					Sensitive:  true,
+				},
+			},
+		},
+	}
+	assert.Equal(t, ErrNotParsed, app.Reload(), "Reload should fail before the app is run.")
+	assert.NoError(t, app.RunE([]string{"path_to_exec", "--name=flag"}))
+	assert.Equal(t, 1, port)
+
+	writeConfig("[basic]\nport=2\nname=\"second\"\ntoken=\"secret\"\n")
+	assert.NoError(t, app.Reload())
+	assert.Equal(t, 2, port)
+	assert.Equal(t, "flag", name, "Flags should be kept on reload.")
+	assert.Equal(t, "secret", token)
+	select {
+	case c := <-changes:
+		assert.Equal(t, []*Change{
+			&Change{Command: "basic", Variable: "port", OldValue: 1, NewValue: 2, Source: TomlConfig, SettingName: path},
+			&Change{Command: "basic", Variable: "token", OldValue: maskedValue, NewValue: maskedValue, Source: TomlConfig, SettingName: path},
+		}, c)
+	default:
+		t.Error("OnChange should be called after a reload.")
+	}
+	assert.Equal(t, map[string]interface{}{"basic.port": 2, "basic.name": "flag", "basic.token": "secret"}, app.Snapshot())
+
+	writeConfig("[basic]\nport=0\n")
+	err = app.Reload()
+	_, ok := err.(ValidationErrors)
+	assert.True(t, ok, "Invalid values should fail the reload.")
+	assert.Equal(t, 2, port, "The previous values should be restored.")
+	assert.Equal(t, "secret", token)
+
+	writeConfig("[basic\n")
+	assert.Error(t, app.Reload(), "Invalid files should fail the reload.")
+	assert.Equal(t, 2, port)
+	assert.Empty(t, changes, "Failed reloads should not call OnChange.")
+
+	runCtx := app.ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- app.Watch(ctx, 5*time.Millisecond)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	writeConfig("[basic]\nport=3000\n")
+	select {
+	case c := <-changes:
+		assert.Equal(t, "port", c[0].Variable)
+		app.View(func() {
+			assert.Equal(t, 3000, port)
+		})
+	case <-time.After(2 * time.Second):
+		t.Error("Watch should reload when the config file changes.")
+	}
+	cancel()
+	assert.Equal(t, context.Canceled, <-watchErr)
+	assert.Equal(t, runCtx, app.ctx, "Watch should restore the app's context.")
+
+	assert.Equal(t, ErrInvalidInterval, app.Watch(context.Background(), -time.Second))
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		watchErr <- app.Watch(ctx, 0)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	writeConfig("[basic]\nport=4000\n")
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.Equal(t, context.Canceled, <-watchErr)
+	assert.Equal(t, 3000, port, "A zero interval should only reload on SIGHUP.")
+}
+
+func TestReloadRemovedValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unpuzzled")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.toml")
+	writeConfig := func(contents string) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+	writeConfig("[basic]\nlevel=\"debug\"\nport=10\n")
+
+	level := "info"
+	var port int
+	var changes []*Change
+	app := NewApp()
+	app.Silent = true
+	app.OnChange = func(c []*Change) {
+		changes = c
+	}
+	app.Command = &Command{
+		Name: "basic",
+		Variables: []Variable{
+			&ConfigVariable{
+				StringVariable: &StringVariable{
+					Name:    "config",
+					Default: path,
+				},
+				Type: TomlConfig,
+			},
+			&StringVariable{
+				Name:            "level",
+				Destination:     &level,
+				VariableOptions: VariableOptions{Reloadable: true},
+			},
+			&IntVariable{
+				Name:            "port",
+				Destination:     &port,
+				VariableOptions: VariableOptions{Reloadable: true},
+			},
+		},
+	}
+	assert.NoError(t, app.RunE([]string{"path_to_exec"}))
+	assert.Equal(t, "debug", level)
+	assert.Equal(t, 10, port)
+
+	writeConfig("[basic]\n")
+	assert.NoError(t, app.Reload())
+	assert.Equal(t, "info", level, "Removed values should be reset to the value before the run.")
+	assert.Equal(t, 0, port)
+	assert.Equal(t, []*Change{
+		&Change{Command: "basic", Variable: "level", OldValue: "debug", NewValue: "info", Source: DefaultValue},
+		&Change{Command: "basic", Variable: "port", OldValue: 10, NewValue: 0, Source: DefaultValue},
+	}, changes)
+
+	writeConfig("[basic]\nport=20\n")
+	assert.NoError(t, app.Reload())
+	assert.Equal(t, "info", level)
+	assert.Equal(t, 20, port)
+}
+
+func TestReloadPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unpuzzled")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.toml")
+	writeConfig := func(contents string) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+	writeConfig("[basic]\nlisten=\":80\"\nlevel=\"info\"\nworkers=1\n")
+
+	var listen, level string
+	var workers int
+	var levelChanges [][]interface{}
+	var changes []*Change
+	app := NewApp()
+	app.RemoveColor = true
+	app.OnChange = func(c []*Change) {
+		changes = c
+	}
+	app.Command = &Command{
+		Name: "basic",
+		Variables: []Variable{
+			&ConfigVariable{
+				StringVariable: &StringVariable{
+					Name:    "config",
+					Default: path,
+				},
+				Type: TomlConfig,
+			},
+			&StringVariable{
+				Name:        "listen",
+				Destination: &listen,
+			},
+			&StringVariable{
+				Name:        "level",
+				Destination: &level,
+				VariableOptions: VariableOptions{
+					Reloadable: true,
+					OnChange: func(old, new interface{}) error {
+						levelChanges = append(levelChanges, []interface{}{old, new})
+						return nil
+					},
+				},
+			},
+			&IntVariable{
+				Name:        "workers",
+				Destination: &workers,
+				VariableOptions: VariableOptions{
+					Reloadable: true,
+					OnChange: func(old, new interface{}) error {
+						if new.(int) < old.(int) {
+							return errors.New("Workers can't be removed.")
+						}
+						return nil
+					},
+				},
+			},
+		},
+	}
+	captureStdout(t, func() {
+		assert.NoError(t, app.RunE([]string{"path_to_exec"}))
+	})
+
+	writeConfig("[basic]\nlisten=\":8080\"\nlevel=\"debug\"\nworkers=0\n")
+	var reloadErr error
+	output := captureStdout(t, func() {
+		reloadErr = app.Reload()
+	})
+	reloadErrors, ok := reloadErr.(ReloadErrors)
+	if assert.True(t, ok, "Rejected changes should be returned as ReloadErrors.") && assert.Len(t, reloadErrors, 2) {
+		assert.Equal(t, "listen", reloadErrors[0].Variable)
+		assert.Equal(t, ErrNotReloadable, reloadErrors[0].Err)
+		assert.Equal(t, ":80", reloadErrors[0].OldValue)
+		assert.Equal(t, ":8080", reloadErrors[0].NewValue)
+		assert.Equal(t, "workers", reloadErrors[1].Variable)
+		assert.EqualError(t, reloadErrors[1].Err, "Workers can't be removed.")
+	}
+	assert.Equal(t, ":80", listen, "Non reloadable variables should keep their value.")
+	assert.Equal(t, 1, workers, "Changes rejected by OnChange should keep the previous value.")
+	assert.Equal(t, "debug", level, "Other changes should still be applied.")
+	assert.Equal(t, [][]interface{}{[]interface{}{"info", "debug"}}, levelChanges)
+	if assert.Len(t, changes, 1) {
+		assert.Equal(t, "level", changes[0].Variable)
+	}
+	assert.Equal(t, ":80", app.settingsMap.MainMap["basic"]["listen"][0].Value, "The kept setting should be restored.")
+
+	assert.Contains(t, output, "Rejected Changes:")
+	assert.Contains(t, output, "listen = :8080 (string)\n\trejected from Toml Config ("+path+")")
+	assert.Contains(t, output, "listen = :80 (string)\n\tset from Toml Config ("+path+")")
+	assert.NotContains(t, output, "level")
+}
+
+func TestReloadRejectedConstraints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unpuzzled")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.toml")
+	writeConfig := func(contents string) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+	writeConfig("[basic]\n")
+
+	var cert, key string
+	keyVariable := &StringVariable{
+		Name:        "key",
+		Destination: &key,
+	}
+	app := NewApp()
+	app.Silent = true
+	app.Command = &Command{
+		Name: "basic",
+		Variables: []Variable{
+			&ConfigVariable{
+				StringVariable: &StringVariable{
+					Name:    "config",
+					Default: path,
+				},
+				Type: TomlConfig,
+			},
+			&StringVariable{
+				Name:        "cert",
+				Destination: &cert,
+				VariableOptions: VariableOptions{
+					Reloadable: true,
+				},
+			},
+			keyVariable,
+		},
+		ConstraintGroups: []*ConstraintGroup{
+			RequiredTogether("cert", "key"),
+		},
+	}
+	assert.NoError(t, app.RunE([]string{"path_to_exec"}))
+
+	writeConfig("[basic]\ncert=\"a.pem\"\nkey=\"a.key\"\n")
+	_, ok := app.Reload().(ConstraintGroupErrors)
+	assert.True(t, ok, "Reverting a non reloadable change should not leave an invalid combination.")
+	assert.Equal(t, "", cert, "Every value should be restored.")
+	assert.Equal(t, "", key)
+
+	keyVariable.Reloadable = true
+	keyVariable.OnChange = func(old, new interface{}) error {
+		return errors.New("Keys can't be changed.")
+	}
+	_, ok = app.Reload().(ConstraintGroupErrors)
+	assert.True(t, ok, "Reverting a rejected change should not leave an invalid combination.")
+	assert.Equal(t, "", cert, "Every value should be restored.")
+	assert.Equal(t, "", key)
+
+	keyVariable.OnChange = nil
+	assert.NoError(t, app.Reload())
+	assert.Equal(t, "a.pem", cert)
+	assert.Equal(t, "a.key", key)
+}
@@ -1,13 +1,14 @@
 package unpuzzled
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
-
-	log "github.com/sirupsen/logrus"
 )
 
 type (
@@ -20,11 +21,22 @@ type (
 		Name            string
 		Usage           string
 		LongDescription string
-		BeforeFunc      func(c *Command) error
-		Subcommands     []*Command
-		Variables       []Variable
-		Action          func()
-		Active          bool
+		// Called for every active command, from the main command down, once all variables are set and before the action.
+		// An error stops the run, and is returned from App.RunE.
+		BeforeFunc func(c *Command) error
+		// Called for every active command, from the last command up, after the action, even if the action failed.
+		// Only called if the command's BeforeFunc succeeded.
+		AfterFunc   func(c *Command) error
+		Subcommands []*Command
+		Variables   []Variable
+		// Rules between the command's variables, ex. unpuzzled.MutuallyExclusive("token", "password")
+		ConstraintGroups []*ConstraintGroup
+		// Called when the command is the last active command. Not called if ActionE is set.
+		Action func()
+		// Called when the command is the last active command, the returned error is returned from App.RunE.
+		// Use NewExitError to choose the exit code used by App.Run.
+		ActionE func(ctx *Context) error
+		Active  bool
 
 		parentCommand *Command
 		flagSet       *flag.FlagSet
@@ -41,6 +53,11 @@ type (
 		Source               ParsingType `json:"source"`
 		SettingName          string      `json:"setting_name"`
 		DuplicateDestination bool        `json:"duplicate_destination"`
+		Merged               bool        `json:"merged"`
+		Key                  string      `json:"key,omitempty"`
+		Alias                string      `json:"alias,omitempty"`
+		Sensitive            bool        `json:"sensitive,omitempty"`
+		Rejected             bool        `json:"rejected,omitempty"`
 	}
 )
 
@@ -48,6 +65,14 @@ func (a *activeSetting) GetFullPath() string {
 	return fmt.Sprintf("%s.%s", a.CommandPath, a.VariableName)
 }
 
+// Name used in the override output, includes the key for map variables.
+func (a *activeSetting) GetDisplayName() string {
+	if a.Key != "" {
+		return fmt.Sprintf("%s[%s]", a.VariableName, a.Key)
+	}
+	return a.VariableName
+}
+
 // Get the expanded name of a command, which includes the name of the parent commands, separated by a "."
 // ex. main.sub1.sub2
 func (c *Command) GetExpandedName() string {
@@ -86,20 +111,36 @@ func (c *Command) GetExpandedActiveCommmands() map[string]*Command {
 }
 
 // Helper to get a map of variables by variable name.
+// If two variables share a name, the later one is kept, use checkDuplicateVariables to detect this.
 func (c *Command) GetVariableMap() map[string]Variable {
 	outMap := make(map[string]Variable)
 	for _, variable := range c.Variables {
-		if _, exists := outMap[variable.GetName()]; exists {
-			log.WithFields(log.Fields{
-				"variable": variable.GetName(),
-				"command":  c.Name,
-			}).Fatal("Duplciate variables seen with the same name.")
-		}
 		outMap[variable.GetName()] = variable
 	}
 	return outMap
 }
 
+// Ensure that no active command has two variables with the same name.
+func (c *Command) checkDuplicateVariables() error {
+	var err error
+	c.loopActiveCommands(func(command *Command) {
+		seen := make(map[string]bool)
+		for _, variable := range command.Variables {
+			// aliases and short flags can't be used by another variable either.
+			for _, name := range flagNames(variable) {
+				if seen[name] && err == nil {
+					err = &DuplicateVariableError{
+						Command:  command.GetExpandedName(),
+						Variable: name,
+					}
+				}
+				seen[name] = true
+			}
+		}
+	})
+	return err
+}
+
 // Adds the parentCommands to all nested commands.
 func (c *Command) buildTree(parentCommand *Command) {
 	if parentCommand != nil {
@@ -112,6 +153,17 @@ func (c *Command) buildTree(parentCommand *Command) {
 	}
 }
 
+// Clear the state of an earlier run from every nested command, so the app can be run again.
+func (c *Command) resetRunState() {
+	c.Active = false
+	c.args = nil
+	c.flagSet = nil
+	c.configVars = nil
+	for _, subCommand := range c.Subcommands {
+		subCommand.resetRunState()
+	}
+}
+
 // Split the arguments between the main command (global arguments), and arguments for each nested subcommand.
 // ex: go run main.go [global flags] subcommand [subcommand arguments] another-subcommand [another-subcommand arguments]
 func (c *Command) assignArguments(args []string) *Command {
@@ -151,20 +203,21 @@ func (c *Command) applyDefaultValues() {
 	})
 }
 
-func (c *Command) parseFlags() error {
+// Parse the flags of every active command. The flag package stops at the first invalid flag,
+// so at most one error is returned per command.
+func (c *Command) parseFlags() ParseErrors {
+	var parseErrors ParseErrors
 	if c.Subcommands != nil {
 		for _, command := range c.Subcommands {
 			if !command.Active {
 				continue
 			}
-			if err := command.parseFlags(); err != nil {
-				return err
-			}
+			parseErrors = append(parseErrors, command.parseFlags()...)
 		}
 	}
 
 	if c.args == nil {
-		return nil
+		return parseErrors
 	}
 
 	c.flagSet = flag.NewFlagSet(c.Name, flag.ContinueOnError)
@@ -172,11 +225,44 @@ func (c *Command) parseFlags() error {
 
 	for _, variable := range c.Variables {
 		variable.setFlag(c.flagSet)
+		// aliases share the flag.Value of the main flag.
+		mainFlag := c.flagSet.Lookup(variable.GetName())
+		for _, name := range flagNames(variable)[1:] {
+			c.flagSet.Var(mainFlag.Value, name, mainFlag.Usage)
+		}
 	}
 	err := c.flagSet.Parse(c.args[:])
 
 	if err != nil {
-		return err
+		parseError := &ParseError{
+			Source:  CliFlags,
+			Command: c.GetExpandedName(),
+			Err:     err,
+		}
+		// match the flag back to its variable, so sensitive values are masked.
+		if match := flagValueErrorRegexp.FindStringSubmatch(err.Error()); match != nil {
+			if variable := c.findFlagVariable(match[2]); variable != nil {
+				parseError.Variable = variable.GetName()
+				parseError.RawValue, _ = strconv.Unquote(match[1])
+			}
+		}
+		parseErrors = append(parseErrors, parseError)
+	}
+	return parseErrors
+}
+
+// Matches the flag package error for a value that can't be parsed, ex. invalid value "abc" for flag -port: parse error
+// or invalid boolean value "abc" for -debug: parse error
+var flagValueErrorRegexp = regexp.MustCompile(`^invalid (?:boolean )?value ("(?:[^"\\]|\\.)*") for (?:flag )?-([^:]+):`)
+
+// Find the variable of a flag name, including aliases and short flags.
+func (c *Command) findFlagVariable(name string) Variable {
+	for _, variable := range c.Variables {
+		for _, flagName := range flagNames(variable) {
+			if flagName == name {
+				return variable
+			}
+		}
 	}
 	return nil
 }
@@ -201,16 +287,19 @@ func (c *Command) getConfigVarsByType(configType ParsingType) []*ConfigVariable
 	return vars
 }
 
-func (c *Command) parseConfigVars() error {
+func (c *Command) parseConfigVars(loaders configLoaderLookup, envNames func(*Command, Variable) []string) error {
+	var err error
 	c.loopActiveCommands(func(command *Command) {
-		if len(command.configVars) == 0 {
-			return
-		}
 		for _, config := range command.configVars {
-			config.ParseConfig(c.flagSet)
+			if err != nil {
+				return
+			}
+			if configErr := config.parseConfig(command.flagSet, envNames(command, config), loaders); configErr != nil && configErr != ErrConfigValueNotSet {
+				err = configErr
+			}
 		}
 	})
-	return nil
+	return err
 }
 
 // Helper function to search down the tree of commands and discover if it's a help command.
@@ -263,29 +352,55 @@ func (c *Command) getSetFlags() []*activeSetting {
 	var allSettings []*activeSetting
 	c.loopActiveCommands(func(command *Command) {
 		expandedName := command.GetExpandedName()
-		varMap := command.GetVariableMap()
-
-		command.flagSet.Visit(func(f *flag.Flag) {
-			if variable, ok := varMap[f.Name]; ok {
-				if val, set := variable.getFlagValue(command.flagSet); set {
-					allSettings = append(allSettings, &activeSetting{
-						CommandPath:  expandedName,
-						VariableName: variable.GetName(),
-						Value:        val,
-						Source:       CliFlags,
-						Destination:  variable.GetDestination(),
-					})
-				}
+		for _, variable := range command.Variables {
+			if val, set := variable.getFlagValue(command.flagSet); set {
+				allSettings = append(allSettings, &activeSetting{
+					CommandPath:  expandedName,
+					VariableName: variable.GetName(),
+					Value:        val,
+					Source:       CliFlags,
+					Alias:        getFlagAlias(command.flagSet, variable),
+					Destination:  variable.GetDestination(),
+				})
 			}
-		})
+		}
 	})
 	return allSettings
 }
 
+// The alias or short flag used to set a variable, empty if the Name was used.
+func getFlagAlias(set *flag.FlagSet, variable Variable) string {
+	if isFlagSet(set, variable.GetName()) {
+		return ""
+	}
+	options := variable.options()
+	for _, alias := range options.Aliases {
+		if isFlagSet(set, alias) {
+			return "--" + alias
+		}
+	}
+	if options.Short != "" && isFlagSet(set, options.Short) {
+		return "-" + options.Short
+	}
+	return ""
+}
+
 func (c *Command) getDefaultValues() []*activeSetting {
 	var allSettings []*activeSetting
 	c.loopActiveVariables(func(command *Command, variable Variable) {
 		expandedName := command.GetExpandedName()
+		if config, ok := variable.(*ConfigVariable); ok {
+			if value, source, isSet := config.getDefaultSetting(); isSet {
+				allSettings = append(allSettings, &activeSetting{
+					CommandPath:  expandedName,
+					VariableName: variable.GetName(),
+					Value:        value,
+					Source:       source,
+					Destination:  variable.GetDestination(),
+				})
+			}
+			return
+		}
 		if value, isSet := variable.GetDefault(); isSet {
 			allSettings = append(allSettings, &activeSetting{
 				CommandPath:  expandedName,
@@ -301,50 +416,185 @@ func (c *Command) getDefaultValues() []*activeSetting {
 
 // loop through all active variables (including variables from subcommands),
 // set from ENV vars. Return all the values that have been set.
-func (c *Command) parseEnvVars() []*activeSetting {
+func (c *Command) parseEnvVars(envNames func(*Command, Variable) []string) ([]*activeSetting, ParseErrors) {
 	var allSettings []*activeSetting
+	var parseErrors ParseErrors
 	c.loopActiveVariables(func(command *Command, variable Variable) {
 		expandedName := command.GetExpandedName()
-		envName := convertNameToOS(variable.GetName())
-		if value, found := os.LookupEnv(envName); found {
-			if val, set := variable.setEnv(value, envName); set {
-				allSettings = append(allSettings, &activeSetting{
-					CommandPath:  expandedName,
-					VariableName: variable.GetName(),
-					Value:        val,
-					Source:       EnvironmentVariables,
-					Destination:  variable.GetDestination(),
+		names := envNames(command, variable)
+		if value, envName, found := lookupEnv(names); found {
+			val, err := variable.setEnv(value, envName)
+			if err != nil {
+				parseErrors = append(parseErrors, &ParseError{
+					Source:   EnvironmentVariables,
+					Command:  expandedName,
+					Variable: variable.GetName(),
+					RawValue: value,
+					Err:      err,
 				})
+				return
 			}
+			allSettings = append(allSettings, &activeSetting{
+				CommandPath:  expandedName,
+				VariableName: variable.GetName(),
+				Value:        val,
+				Source:       EnvironmentVariables,
+				SettingName:  envName,
+				Alias:        envAlias(variable, names, envName),
+				Destination:  variable.GetDestination(),
+			})
 		}
 	})
-	return allSettings
+	return allSettings, parseErrors
 }
 
-func (c *Command) parseConfigValues(configVars []*ConfigVariable) []*activeSetting {
+// loop through all active variables, set from the files in <ENV NAME>_FILE environment variables, or the FromFile path.
+// Values are always masked, the trailing newline is trimmed.
+func (c *Command) parseSecretFiles(envNames func(*Command, Variable) []string) ([]*activeSetting, ParseErrors) {
 	var allSettings []*activeSetting
+	var parseErrors ParseErrors
 	c.loopActiveVariables(func(command *Command, variable Variable) {
+		// config paths are resolved by the ConfigVariable.
+		if _, ok := variable.(*ConfigVariable); ok {
+			return
+		}
 		expandedName := command.GetExpandedName()
-		for _, configVar := range configVars {
-			currPath := fmt.Sprintf("%s.%s", expandedName, variable.GetName())
-			value, err := configVar.getConfigValue(currPath)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"variable": variable.GetName(),
-					"config":   configVar.GetName(),
-				}).Fatal("Failed while parsing config variable.")
+		names := envNames(command, variable)
+		fileNames := make([]string, len(names))
+		for i, name := range names {
+			fileNames[i] = name + "_FILE"
+		}
+		path, envName, found := lookupEnv(fileNames)
+		if !found {
+			// a missing FromFile is skipped, ex. a secret that's only mounted in production.
+			path = variable.options().FromFile
+			if _, err := os.Stat(path); path == "" || os.IsNotExist(err) {
+				return
 			}
-			if value != nil {
-				allSettings = append(allSettings, &activeSetting{
-					CommandPath:  expandedName,
-					VariableName: variable.GetName(),
-					Value:        value,
-					Source:       configVar.Type,
-					SettingName:  configVar.GetName(),
-					Destination:  variable.GetDestination(),
-				})
+		}
+		addError := func(err error) {
+			parseErrors = append(parseErrors, &ParseError{
+				Source:   SecretFile,
+				Command:  expandedName,
+				Variable: variable.GetName(),
+				RawValue: maskedValue,
+				Err:      err,
+			})
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			addError(err)
+			return
+		}
+		value := strings.TrimRight(string(contents), "\r\n")
+		val, err := variable.setEnv(value, strings.TrimSuffix(envName, "_FILE"))
+		if err != nil {
+			if value != "" {
+				err = errors.New(strings.Replace(err.Error(), value, maskedValue, -1))
 			}
+			addError(err)
+			return
+		}
+		alias := ""
+		if envName != "" {
+			alias = envAlias(variable, names, strings.TrimSuffix(envName, "_FILE"))
 		}
+		allSettings = append(allSettings, &activeSetting{
+			CommandPath:  expandedName,
+			VariableName: variable.GetName(),
+			Value:        val,
+			Source:       SecretFile,
+			SettingName:  path,
+			Alias:        alias,
+			Destination:  variable.GetDestination(),
+			Sensitive:    true,
+		})
 	})
-	return allSettings
+	return allSettings, parseErrors
+}
+
+// The alias for an environment variable name, names are in the order from App.getEnvNames.
+func envAlias(variable Variable, names []string, envName string) string {
+	for i, alias := range variable.options().Aliases {
+		if i+1 < len(names) && names[i+1] == envName {
+			return alias
+		}
+	}
+	return ""
+}
+
+// Look up a variable in a config file by its name and aliases, returning the name that was found.
+// .env files use the same names as environment variables.
+func lookupConfigValue(source ConfigSource, configType ParsingType, commandPath string, names []string, envNames []string) (interface{}, string, error) {
+	if configType == DotEnvConfig {
+		names = envNames
+	}
+	for _, name := range names {
+		path := name
+		if configType != DotEnvConfig {
+			path = fmt.Sprintf("%s.%s", commandPath, name)
+		}
+		value, err := source.Lookup(path)
+		if value != nil || err != nil {
+			return value, name, err
+		}
+	}
+	return nil, "", nil
+}
+
+func (c *Command) parseConfigValues(configVars []*ConfigVariable, envNames func(*Command, Variable) []string) ([]*activeSetting, ParseErrors) {
+	var allSettings []*activeSetting
+	var parseErrors ParseErrors
+	c.loopActiveVariables(func(command *Command, variable Variable) {
+		expandedName := command.GetExpandedName()
+		names := append([]string{variable.GetName()}, variable.options().Aliases...)
+		variableEnvNames := envNames(command, variable)
+		for _, configVar := range configVars {
+			// files are layered in order, the last file with the value is used.
+			for _, file := range configVar.configs {
+				value, foundName, err := lookupConfigValue(file.source, configVar.Type, expandedName, names, variableEnvNames)
+				if err != nil {
+					parseErrors = append(parseErrors, &ParseError{
+						Source:   configVar.Type,
+						Command:  expandedName,
+						Variable: variable.GetName(),
+						Err:      err,
+					})
+					continue
+				}
+				envName, alias := "", ""
+				if configVar.Type == DotEnvConfig {
+					envName = foundName
+					alias = envAlias(variable, variableEnvNames, envName)
+				} else if foundName != variable.GetName() {
+					alias = foundName
+				}
+				// text based formats only have strings, parse them the same way as environment variables.
+				if stringVal, ok := value.(string); ok {
+					if value, err = variable.setEnv(stringVal, envName); err != nil {
+						parseErrors = append(parseErrors, &ParseError{
+							Source:   configVar.Type,
+							Command:  expandedName,
+							Variable: variable.GetName(),
+							RawValue: stringVal,
+							Err:      err,
+						})
+						continue
+					}
+				}
+				if value != nil {
+					allSettings = append(allSettings, &activeSetting{
+						CommandPath:  expandedName,
+						VariableName: variable.GetName(),
+						Value:        value,
+						Source:       configVar.Type,
+						SettingName:  file.path,
+						Alias:        alias,
+						Destination:  variable.GetDestination(),
+					})
+				}
+			}
+		}
+	})
+	return allSettings, parseErrors
 }
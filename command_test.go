@@ -2,6 +2,8 @@ package unpuzzled
 
 import (
 	"fmt"
+	"net"
+	"strconv"
 	"testing"
 	"time"
 
@@ -725,6 +727,44 @@ func TestLoopActiveCommands(t *testing.T) {
 	}
 }
 
+// Settings should be grouped by the command they belong to, in the order of the active commands.
+func TestOrderSettings(t *testing.T) {
+	var first, second, third string
+	app := NewApp()
+	app.Silent = true
+	app.Command = &Command{
+		Name: "main",
+		Variables: []Variable{
+			&StringVariable{Name: "first", Destination: &first, Default: "1"},
+		},
+		Subcommands: []*Command{
+			&Command{
+				Name: "sub",
+				Variables: []Variable{
+					&StringVariable{Name: "second", Destination: &second, Default: "2"},
+					&StringVariable{Name: "third", Destination: &third, Default: "3"},
+				},
+			},
+		},
+	}
+	assert.NoError(t, app.RunE([]string{"path_to_exec", "sub"}))
+
+	app.settingsMap.OrderSettings(app.activeCommands)
+	grouped := make(map[string][]string)
+	var paths []string
+	for _, group := range app.settingsMap.OrderedSettings {
+		paths = append(paths, group.CommandPath)
+		for _, settings := range group.Settings {
+			grouped[group.CommandPath] = append(grouped[group.CommandPath], settings[0].VariableName)
+		}
+	}
+	assert.Equal(t, []string{"main", "main.sub"}, paths)
+	assert.Equal(t, map[string][]string{
+		"main":     []string{"first"},
+		"main.sub": []string{"second", "third"},
+	}, grouped)
+}
+
 type testDefaultValues struct {
 	Name           string
 	Command        *Command
@@ -890,3 +930,1254 @@ func TestRequiredValues(t *testing.T) {
 		})
 	}
 }
+
+type sliceTestConfig struct {
+	Tags     []string
+	Ports    []int
+	Timeouts []time.Duration
+}
+
+type testSliceVariables struct {
+	Name          string
+	AppendSources bool
+	Args          []string
+	EnvVars       []envVar
+	Expected      *sliceTestConfig
+}
+
+// Slice variables accept repeated flags, separated environment variables, and config arrays.
+func TestSliceVariables(t *testing.T) {
+	tests := []testSliceVariables{
+		testSliceVariables{
+			Name: "Repeated CLI flags.",
+			Args: []string{"--tags=a", "--tags=b,c", "--ports=1", "--ports=2", "--timeouts=1s"},
+			Expected: &sliceTestConfig{
+				Tags:     []string{"a", "b", "c"},
+				Ports:    []int{1, 2},
+				Timeouts: []time.Duration{time.Second},
+			},
+		},
+		testSliceVariables{
+			Name: "Environment variables.",
+			Args: []string{},
+			EnvVars: []envVar{
+				envVar{"TAGS", "a, b"},
+				envVar{"PORTS", "1;2;3"},
+				envVar{"TIMEOUTS", "1s,1h"},
+			},
+			Expected: &sliceTestConfig{
+				Tags:     []string{"a", "b"},
+				Ports:    []int{1, 2, 3},
+				Timeouts: []time.Duration{time.Second, time.Hour},
+			},
+		},
+		testSliceVariables{
+			Name: "Config arrays, later sources replace earlier sources.",
+			Args: []string{"--toml=./fixtures/slice_test.toml", "--json=./fixtures/slice_test.json", "--tags=flag"},
+			Expected: &sliceTestConfig{
+				Tags:     []string{"flag"},
+				Ports:    []int{80, 443},
+				Timeouts: []time.Duration{time.Second, time.Minute},
+			},
+		},
+		testSliceVariables{
+			Name:          "Config arrays, sources are appended.",
+			AppendSources: true,
+			Args:          []string{"--toml=./fixtures/slice_test.toml", "--json=./fixtures/slice_test.json", "--tags=flag"},
+			EnvVars: []envVar{
+				envVar{"TAGS", "env"},
+			},
+			Expected: &sliceTestConfig{
+				Tags:     []string{"env", "json-a", "json-b", "toml-a", "toml-b", "flag"},
+				Ports:    []int{8080, 8443, 80, 443},
+				Timeouts: []time.Duration{2 * time.Second, 2 * time.Minute, time.Second, time.Minute},
+			},
+		},
+		testSliceVariables{
+			Name:          "A single source, sources are appended.",
+			AppendSources: true,
+			Args:          []string{"--tags=a", "--tags=b"},
+			Expected: &sliceTestConfig{
+				Tags: []string{"a", "b"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, envVar := range test.EnvVars {
+				os.Setenv(envVar.Key, envVar.Value)
+			}
+
+			config := &sliceTestConfig{}
+			app := NewApp()
+			app.Silent = true
+			app.Command = &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&StringSliceVariable{
+						Name:          "tags",
+						Destination:   &config.Tags,
+						AppendSources: test.AppendSources,
+					},
+					&IntSliceVariable{
+						Name:          "ports",
+						Destination:   &config.Ports,
+						Separator:     ";",
+						AppendSources: test.AppendSources,
+					},
+					&DurationSliceVariable{
+						Name:          "timeouts",
+						Destination:   &config.Timeouts,
+						AppendSources: test.AppendSources,
+					},
+					&ConfigVariable{
+						StringVariable: &StringVariable{
+							Name: "toml",
+						},
+						Type: TomlConfig,
+					},
+					&ConfigVariable{
+						StringVariable: &StringVariable{
+							Name: "json",
+						},
+						Type: JsonConfig,
+					},
+				},
+			}
+			assert.NoError(t, app.RunE(append([]string{"path_to_exec"}, test.Args...)))
+			assert.Equal(t, test.Expected, config, "Slice values should be equal.")
+			for _, name := range []string{"tags", "ports", "timeouts"} {
+				settings := app.settingsMap.MainMap["basic"][name]
+				for _, setting := range settings {
+					assert.Equal(t, test.AppendSources && len(settings) > 1, setting.Merged, "Only values from more than one source should be shown as appended.")
+				}
+			}
+
+			for _, envVar := range test.EnvVars {
+				assert.NoError(t, os.Unsetenv(envVar.Key), "Should not error while unsetting the env var.")
+			}
+		})
+	}
+}
+
+type testMapVariables struct {
+	Name           string
+	Args           []string
+	EnvVars        []envVar
+	ExpectedLabels map[string]string
+	ExpectedLimits map[string]int
+}
+
+// Map variables are merged by key across every source.
+func TestMapVariables(t *testing.T) {
+	tests := []testMapVariables{
+		testMapVariables{
+			Name:           "Defaults only.",
+			Args:           []string{},
+			ExpectedLabels: map[string]string{"team": "default-team"},
+			ExpectedLimits: map[string]int{},
+		},
+		testMapVariables{
+			Name: "Repeated CLI flags and environment variables.",
+			Args: []string{"--labels=team=flag-team", "--labels", "a=1,b=2", "--limits=cpu=4"},
+			EnvVars: []envVar{
+				envVar{"LABELS", "b=env,c=env"},
+				envVar{"LIMITS", "memory=128"},
+			},
+			ExpectedLabels: map[string]string{"team": "flag-team", "a": "1", "b": "2", "c": "env"},
+			ExpectedLimits: map[string]int{"cpu": 4, "memory": 128},
+		},
+		testMapVariables{
+			Name:           "Config tables and objects.",
+			Args:           []string{"--toml=./fixtures/map_test.toml", "--json=./fixtures/map_test.json"},
+			ExpectedLabels: map[string]string{"team": "toml-team", "region": "toml-region"},
+			ExpectedLimits: map[string]int{"cpu": 2, "memory": 512},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, envVar := range test.EnvVars {
+				os.Setenv(envVar.Key, envVar.Value)
+			}
+
+			labels := map[string]string{}
+			limits := map[string]int{}
+			app := NewApp()
+			app.Silent = true
+			app.Command = &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&StringMapVariable{
+						Name:        "labels",
+						Destination: &labels,
+						Default:     map[string]string{"team": "default-team"},
+					},
+					&IntMapVariable{
+						Name:        "limits",
+						Destination: &limits,
+					},
+					&ConfigVariable{
+						StringVariable: &StringVariable{
+							Name: "toml",
+						},
+						Type: TomlConfig,
+					},
+					&ConfigVariable{
+						StringVariable: &StringVariable{
+							Name: "json",
+						},
+						Type: JsonConfig,
+					},
+				},
+			}
+			assert.NoError(t, app.RunE(append([]string{"path_to_exec"}, test.Args...)))
+			assert.Equal(t, test.ExpectedLabels, labels, "Map values should be equal.")
+			assert.Equal(t, test.ExpectedLimits, limits, "Map values should be equal.")
+
+			for _, envVar := range test.EnvVars {
+				assert.NoError(t, os.Unsetenv(envVar.Key), "Should not error while unsetting the env var.")
+			}
+		})
+	}
+}
+
+// Example flag.Value used to test GenericVariable.
+type testLevel int
+
+func (l *testLevel) String() string {
+	return strconv.Itoa(int(*l))
+}
+
+func (l *testLevel) Set(value string) error {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	if parsed < 0 || parsed > 5 {
+		return fmt.Errorf("Level must be between 0 and 5.")
+	}
+	*l = testLevel(parsed)
+	return nil
+}
+
+type testGenericVariables struct {
+	Name          string
+	Args          []string
+	EnvVars       []envVar
+	ExpectedIP    net.IP
+	ExpectedLevel testLevel
+	ExpectedErr   bool
+}
+
+// GenericVariable parses values through flag.Value or encoding.TextUnmarshaler from every source.
+func TestGenericVariables(t *testing.T) {
+	tests := []testGenericVariables{
+		testGenericVariables{
+			Name:          "Defaults.",
+			Args:          []string{},
+			ExpectedIP:    net.ParseIP("127.0.0.1"),
+			ExpectedLevel: testLevel(0),
+		},
+		testGenericVariables{
+			Name:          "CLI flags.",
+			Args:          []string{"--ip=192.168.0.1", "--level=2"},
+			ExpectedIP:    net.ParseIP("192.168.0.1"),
+			ExpectedLevel: testLevel(2),
+		},
+		testGenericVariables{
+			Name: "Environment variables.",
+			Args: []string{},
+			EnvVars: []envVar{
+				envVar{"IP", "::1"},
+				envVar{"LEVEL", "4"},
+			},
+			ExpectedIP:    net.ParseIP("::1"),
+			ExpectedLevel: testLevel(4),
+		},
+		testGenericVariables{
+			Name:          "Config strings and numbers.",
+			Args:          []string{"--config=./fixtures/generic_test.json"},
+			ExpectedIP:    net.ParseIP("10.0.0.1"),
+			ExpectedLevel: testLevel(3),
+		},
+		testGenericVariables{
+			Name:        "Invalid value.",
+			Args:        []string{"--level=10"},
+			ExpectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, envVar := range test.EnvVars {
+				os.Setenv(envVar.Key, envVar.Value)
+			}
+
+			var ip net.IP
+			var level testLevel
+			app := NewApp()
+			app.Silent = true
+			app.Command = &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&GenericVariable{
+						Name:        "ip",
+						Destination: &ip,
+						Default:     "127.0.0.1",
+					},
+					&GenericVariable{
+						Name:        "level",
+						Destination: &level,
+					},
+					&ConfigVariable{
+						StringVariable: &StringVariable{
+							Name: "config",
+						},
+						Type: JsonConfig,
+					},
+				},
+			}
+			err := app.RunE(append([]string{"path_to_exec"}, test.Args...))
+			if test.ExpectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.ExpectedIP, ip, "IP values should be equal.")
+				assert.Equal(t, test.ExpectedLevel, level, "Level values should be equal.")
+			}
+
+			for _, envVar := range test.EnvVars {
+				assert.NoError(t, os.Unsetenv(envVar.Key), "Should not error while unsetting the env var.")
+			}
+		})
+	}
+}
+
+// Example encoding.TextUnmarshaler without a String method, shown by value in the override output.
+type testMode struct {
+	name string
+}
+
+func (m *testMode) UnmarshalText(text []byte) error {
+	m.name = string(text)
+	return nil
+}
+
+func TestURLValue(t *testing.T) {
+	var endpoint URLValue
+	var mode testMode
+	newApp := func() *App {
+		app := NewApp()
+		app.RemoveColor = true
+		app.Command = &Command{
+			Name: "basic",
+			Variables: []Variable{
+				&GenericVariable{
+					Name:        "endpoint",
+					Default:     "http://localhost:8080",
+					Destination: &endpoint,
+				},
+				&GenericVariable{
+					Name:        "mode",
+					Destination: &mode,
+				},
+			},
+		}
+		return app
+	}
+
+	var err error
+	output := captureStdout(t, func() {
+		err = newApp().RunE([]string{"path_to_exec", "--endpoint=https://example.com/api", "--mode=fast"})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", endpoint.Host)
+	assert.Equal(t, "https://example.com/api", endpoint.String())
+	assert.Equal(t, "fast", mode.name)
+	assert.Contains(t, output, "endpoint = https://example.com/api (unpuzzled.URLValue)")
+	assert.Contains(t, output, "mode = {fast} (unpuzzled.testMode)", "Generic values should be shown by value, not as a pointer.")
+
+	app := newApp()
+	app.Silent = true
+	assert.Error(t, app.RunE([]string{"path_to_exec", "--endpoint=http://[::1"}), "Invalid URLs should be an error.")
+}
+
+type testZeroValues struct {
+	Name             string
+	Command          func(config *fullTestConfig) *Command
+	Args             []string
+	EnvVars          []envVar
+	Expected         *fullTestConfig
+	ExpectedRequired bool
+}
+
+// Zero values are valid settings when they're actually passed, and unset flags never override other sources.
+func TestZeroValues(t *testing.T) {
+	variables := func(config *fullTestConfig) []Variable {
+		return []Variable{
+			&IntVariable{
+				Name:        "test-int",
+				Destination: &config.TestInt,
+			},
+			&BoolVariable{
+				Name:        "test-bool",
+				Destination: &config.TestBool,
+			},
+			&DurationVariable{
+				Name:        "test-duration",
+				Destination: &config.TestDuration,
+			},
+			&StringVariable{
+				Name:        "test-value",
+				Destination: &config.TestString,
+			},
+		}
+	}
+
+	tests := []testZeroValues{
+		testZeroValues{
+			Name: "Zero value flags override the environment.",
+			Command: func(config *fullTestConfig) *Command {
+				return &Command{
+					Name:      "basic",
+					Variables: variables(config),
+				}
+			},
+			Args: []string{"--test-int=0", "--test-bool=false", "--test-duration=0s", "--test-value="},
+			EnvVars: []envVar{
+				envVar{"TEST_INT", "8080"},
+				envVar{"TEST_BOOL", "TRUE"},
+				envVar{"TEST_DURATION", "1m"},
+				envVar{"TEST_VALUE", "env"},
+			},
+			Expected: &fullTestConfig{},
+		},
+		testZeroValues{
+			Name: "Unset flags don't override the environment.",
+			Command: func(config *fullTestConfig) *Command {
+				return &Command{
+					Name:      "basic",
+					Variables: variables(config),
+				}
+			},
+			Args: []string{},
+			EnvVars: []envVar{
+				envVar{"TEST_INT", "8080"},
+				envVar{"TEST_BOOL", "TRUE"},
+			},
+			Expected: &fullTestConfig{
+				TestInt:  8080,
+				TestBool: true,
+			},
+		},
+		testZeroValues{
+			Name: "Zero defaults with HasDefault are set, and satisfy required variables.",
+			Command: func(config *fullTestConfig) *Command {
+				return &Command{
+					Name: "basic",
+					Variables: []Variable{
+						&IntVariable{
+							Name:        "test-int",
+							Destination: &config.TestInt,
+							HasDefault:  true,
+							Required:    true,
+						},
+						&BoolVariable{
+							Name:        "test-bool",
+							Destination: &config.TestBool,
+							HasDefault:  true,
+							Required:    true,
+						},
+					},
+				}
+			},
+			Args:     []string{},
+			Expected: &fullTestConfig{},
+		},
+		testZeroValues{
+			Name: "Zero defaults without HasDefault are not set.",
+			Command: func(config *fullTestConfig) *Command {
+				return &Command{
+					Name: "basic",
+					Variables: []Variable{
+						&IntVariable{
+							Name:        "test-int",
+							Destination: &config.TestInt,
+							Required:    true,
+						},
+					},
+				}
+			},
+			Args:             []string{},
+			Expected:         &fullTestConfig{},
+			ExpectedRequired: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, envVar := range test.EnvVars {
+				os.Setenv(envVar.Key, envVar.Value)
+			}
+
+			config := &fullTestConfig{}
+			app := NewApp()
+			app.Silent = true
+			app.Command = test.Command(config)
+			err := app.RunE(append([]string{"path_to_exec"}, test.Args...))
+			if test.ExpectedRequired {
+				assert.IsType(t, &MissingRequiredError{}, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.Expected, config, "test config values should be equal.")
+
+			for _, envVar := range test.EnvVars {
+				assert.NoError(t, os.Unsetenv(envVar.Key), "Should not error while unsetting the env var.")
+			}
+		})
+	}
+}
+
+func TestYamlConfig(t *testing.T) {
+	config := &fullTestConfig{}
+	nestedConfig := &fullTestConfig{}
+	var tags []string
+	var labels map[string]string
+
+	app := NewApp()
+	app.Silent = true
+	app.Command = &Command{
+		Name: "basic",
+		Variables: []Variable{
+			&Float64Variable{
+				Name:        "test-float",
+				Destination: &config.TestFloat64,
+			},
+			&StringVariable{
+				Name:        "test-string",
+				Destination: &config.TestString,
+			},
+			&BoolVariable{
+				Name:        "test-bool",
+				Destination: &config.TestBool,
+			},
+			&IntVariable{
+				Name:        "test-int",
+				Destination: &config.TestInt,
+			},
+			&Int64Variable{
+				Name:        "test-int-64",
+				Destination: &config.TestInt64,
+			},
+			&DurationVariable{
+				Name:        "test-duration",
+				Destination: &config.TestDuration,
+			},
+			&StringSliceVariable{
+				Name:        "test-tags",
+				Destination: &tags,
+			},
+			&StringMapVariable{
+				Name:        "test-labels",
+				Destination: &labels,
+			},
+			&ConfigVariable{
+				StringVariable: &StringVariable{
+					Required:    true,
+					Name:        "config",
+					Description: "Main configuration",
+				},
+				Type: YamlConfig,
+			},
+		},
+		Subcommands: []*Command{
+			&Command{
+				Name: "nested",
+				Variables: []Variable{
+					&Float64Variable{
+						Name:        "test-float",
+						Destination: &nestedConfig.TestFloat64,
+					},
+					&StringVariable{
+						Name:        "test-string",
+						Destination: &nestedConfig.TestString,
+					},
+					&BoolVariable{
+						Name:        "test-bool",
+						Destination: &nestedConfig.TestBool,
+					},
+					&IntVariable{
+						Name:        "test-int",
+						Destination: &nestedConfig.TestInt,
+					},
+					&Int64Variable{
+						Name:        "test-int-64",
+						Destination: &nestedConfig.TestInt64,
+					},
+					&DurationVariable{
+						Name:        "test-duration",
+						Destination: &nestedConfig.TestDuration,
+					},
+				},
+			},
+		},
+	}
+	assert.NoError(t, app.RunE([]string{"path_to_exec", "--config=./fixtures/basic_test.yaml", "nested"}))
+	assert.Equal(t, &fullTestConfig{
+		TestFloat64:  float64(1.2345),
+		TestString:   "hi",
+		TestBool:     true,
+		TestInt:      5,
+		TestInt64:    int64(100),
+		TestDuration: time.Hour,
+	}, config, "config values should be the same.")
+	assert.Equal(t, &fullTestConfig{
+		TestFloat64:  float64(2.5),
+		TestString:   "nested",
+		TestBool:     true,
+		TestInt:      6,
+		TestInt64:    int64(200),
+		TestDuration: time.Minute,
+	}, nestedConfig, "nested config values should be the same.")
+	assert.Equal(t, []string{"a", "b"}, tags)
+	assert.Equal(t, map[string]string{"team": "yaml"}, labels)
+}
+
+type testConfigPaths struct {
+	Name           string
+	Config         *ConfigVariable
+	Args           []string
+	EnvVars        []envVar
+	ExpectedPath   string
+	ExpectedSource ParsingType
+	ExpectError    bool
+}
+
+// Config paths are set from flags, env, the default, then the first file found in the search paths.
+func TestConfigPaths(t *testing.T) {
+	tests := []testConfigPaths{
+		testConfigPaths{
+			Name: "Path from the environment.",
+			Config: &ConfigVariable{
+				StringVariable: &StringVariable{
+					Name: "config",
+				},
+				Type: TomlConfig,
+			},
+			EnvVars: []envVar{
+				envVar{"CONFIG", "./fixtures/basic_test.toml"},
+			},
+			ExpectedPath:   "./fixtures/basic_test.toml",
+			ExpectedSource: EnvironmentVariables,
+		},
+		testConfigPaths{
+			Name: "Path from the default.",
+			Config: &ConfigVariable{
+				StringVariable: &StringVariable{
+					Name:    "config",
+					Default: "./fixtures/basic_test.toml",
+				},
+				Type: TomlConfig,
+			},
+			ExpectedPath:   "./fixtures/basic_test.toml",
+			ExpectedSource: DefaultValue,
+		},
+		testConfigPaths{
+			Name: "Missing default uses the search paths.",
+			Config: &ConfigVariable{
+				StringVariable: &StringVariable{
+					Name:     "config",
+					Default:  "./fixtures/missing.toml",
+					Required: true,
+				},
+				Type:        TomlConfig,
+				SearchPaths: []string{"./fixtures/missing/", "$TEST_CONFIG_DIR"},
+				FileName:    "basic_test.toml",
+			},
+			EnvVars: []envVar{
+				envVar{"TEST_CONFIG_DIR", "./fixtures"},
+			},
+			ExpectedPath:   "fixtures/basic_test.toml",
+			ExpectedSource: ConfigSearchPath,
+		},
+		testConfigPaths{
+			Name: "Flag overrides the environment.",
+			Config: &ConfigVariable{
+				StringVariable: &StringVariable{
+					Name: "config",
+				},
+				Type: TomlConfig,
+			},
+			Args: []string{"--config=./fixtures/basic_test.toml"},
+			EnvVars: []envVar{
+				envVar{"CONFIG", "./fixtures/missing.toml"},
+			},
+			ExpectedPath:   "./fixtures/basic_test.toml",
+			ExpectedSource: CliFlags,
+		},
+		testConfigPaths{
+			Name: "Missing file from the environment.",
+			Config: &ConfigVariable{
+				StringVariable: &StringVariable{
+					Name: "config",
+				},
+				Type:        TomlConfig,
+				SearchPaths: []string{"./fixtures/basic_test.toml"},
+			},
+			EnvVars: []envVar{
+				envVar{"CONFIG", "./fixtures/missing.toml"},
+			},
+			ExpectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, envVar := range test.EnvVars {
+				os.Setenv(envVar.Key, envVar.Value)
+			}
+			var testString string
+
+			app := NewApp()
+			app.Silent = true
+			app.Command = &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&StringVariable{
+						Name:        "teststring",
+						Destination: &testString,
+					},
+					test.Config,
+				},
+			}
+			err := app.RunE(append([]string{"path_to_exec"}, test.Args...))
+			if test.ExpectError {
+				_, ok := err.(*ConfigError)
+				assert.True(t, ok, "Error should be a *ConfigError.")
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, []string{test.ExpectedPath}, test.Config.LoadedPaths())
+				assert.Equal(t, "hi", testString, "Values should be loaded from the config file.")
+				settings := app.settingsMap.MainMap["basic"]["config"]
+				if assert.NotEmpty(t, settings) {
+					assert.Equal(t, test.ExpectedSource, settings[len(settings)-1].Source)
+				}
+			}
+
+			for _, envVar := range test.EnvVars {
+				assert.NoError(t, os.Unsetenv(envVar.Key), "Should not error while unsetting the env var.")
+			}
+		})
+	}
+}
+
+func TestConfigSearchPaths(t *testing.T) {
+	home, xdgConfigHome := os.Getenv("HOME"), os.Getenv("XDG_CONFIG_HOME")
+	defer os.Setenv("HOME", home)
+	defer os.Setenv("XDG_CONFIG_HOME", xdgConfigHome)
+	os.Setenv("HOME", "/home/test")
+	os.Unsetenv("XDG_CONFIG_HOME")
+	os.Unsetenv("TEST_UNSET_CONFIG_DIR")
+
+	config := &ConfigVariable{
+		StringVariable: &StringVariable{
+			Name: "config",
+		},
+		Type:        TomlConfig,
+		SearchPaths: []string{"$TEST_UNSET_CONFIG_DIR/app/", "$XDG_CONFIG_HOME/app/", "/etc/app/"},
+		FileName:    "app.toml",
+	}
+	assert.Equal(t, []string{"/home/test/.config/app/app.toml", "/etc/app/app.toml"}, config.getSearchPaths(), "Unset variables should be skipped, and XDG_CONFIG_HOME should default to $HOME/.config.")
+
+	os.Setenv("XDG_CONFIG_HOME", "/xdg")
+	assert.Equal(t, []string{"/xdg/app/app.toml", "/etc/app/app.toml"}, config.getSearchPaths())
+}
+
+type testLayeredConfigs struct {
+	Name    string
+	Args    []string
+	EnvVars []envVar
+}
+
+// Later config files override the values in earlier files, keys in tables are merged.
+func TestLayeredConfigs(t *testing.T) {
+	basePath := "./fixtures/layered_base_test.toml"
+	prodPath := "./fixtures/layered_prod_test.toml"
+	tests := []testLayeredConfigs{
+		testLayeredConfigs{
+			Name: "Repeated flags.",
+			Args: []string{"--config=" + basePath, "--config=" + prodPath},
+		},
+		testLayeredConfigs{
+			Name: "Path list from the environment.",
+			EnvVars: []envVar{
+				envVar{"CONFIG", basePath + string(os.PathListSeparator) + prodPath},
+			},
+		},
+		testLayeredConfigs{
+			Name: "Glob pattern.",
+			Args: []string{"--config=./fixtures/layered_*_test.toml"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, envVar := range test.EnvVars {
+				os.Setenv(envVar.Key, envVar.Value)
+			}
+			var testString string
+			var testInt int
+			var labels map[string]string
+			config := &ConfigVariable{
+				StringVariable: &StringVariable{
+					Name: "config",
+				},
+				Type: TomlConfig,
+			}
+
+			app := NewApp()
+			app.Silent = true
+			app.Command = &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&StringVariable{
+						Name:        "teststring",
+						Destination: &testString,
+					},
+					&IntVariable{
+						Name:        "testint",
+						Destination: &testInt,
+					},
+					&StringMapVariable{
+						Name:        "test-labels",
+						Destination: &labels,
+					},
+					config,
+				},
+			}
+			assert.NoError(t, app.RunE(append([]string{"path_to_exec"}, test.Args...)))
+			assert.Len(t, config.LoadedPaths(), 2, "Both files should be loaded.")
+			assert.Equal(t, "prod", testString)
+			assert.Equal(t, 1, testInt, "Values only in the first file should be kept.")
+			assert.Equal(t, map[string]string{"team": "prod", "region": "us"}, labels)
+
+			settings := app.settingsMap.MainMap["basic"]["teststring"]
+			if assert.Len(t, settings, 2) {
+				assert.Contains(t, settings[0].SettingName, "layered_base_test.toml", "Each setting should name its file.")
+				assert.Contains(t, settings[1].SettingName, "layered_prod_test.toml", "Each setting should name its file.")
+			}
+
+			for _, envVar := range test.EnvVars {
+				assert.NoError(t, os.Unsetenv(envVar.Key), "Should not error while unsetting the env var.")
+			}
+		})
+	}
+
+	app := NewApp()
+	app.Silent = true
+	app.Command = &Command{
+		Name: "basic",
+		Variables: []Variable{
+			&ConfigVariable{
+				StringVariable: &StringVariable{
+					Name: "config",
+				},
+				Type: TomlConfig,
+			},
+		},
+	}
+	err := app.RunE([]string{"path_to_exec", "--config=./fixtures/missing_*.toml"})
+	configErr, ok := err.(*ConfigError)
+	if assert.True(t, ok, "Error should be a *ConfigError.") {
+		assert.Equal(t, ErrNoConfigFiles, configErr.Err)
+	}
+}
+
+type testConfigDirectory struct {
+	Name       string
+	Args       []string
+	Pattern    string
+	Validation func(*testing.T, *App, error)
+}
+
+// Every fragment in a config directory is loaded in lexical order, with the loader for its extension.
+func TestConfigDirectory(t *testing.T) {
+	var testString string
+	var testInt int
+	var labels map[string]string
+
+	tests := []testConfigDirectory{
+		testConfigDirectory{
+			Name: "Fragments are merged in order.",
+			Args: []string{"--config-dir=./fixtures/conf.d"},
+			Validation: func(t *testing.T, app *App, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, "yaml", testString, "The last fragment should win.")
+				assert.Equal(t, 1, testInt, "Hidden files should be skipped.")
+				assert.Equal(t, map[string]string{"team": "base", "region": "eu"}, labels)
+
+				settings := app.settingsMap.MainMap["basic"]["teststring"]
+				if assert.Len(t, settings, 3) {
+					assert.Equal(t, "fixtures/conf.d/10-base.toml", settings[0].SettingName)
+					assert.Equal(t, "fixtures/conf.d/20-override.json", settings[1].SettingName)
+					assert.Equal(t, "fixtures/conf.d/30-final.yaml", settings[2].SettingName)
+					assert.Equal(t, ConfigDirectory, settings[2].Source)
+				}
+			},
+		},
+		testConfigDirectory{
+			Name:    "Pattern filters fragments.",
+			Args:    []string{"--config-dir=./fixtures/conf.d"},
+			Pattern: "*.toml",
+			Validation: func(t *testing.T, app *App, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, "base", testString)
+			},
+		},
+		testConfigDirectory{
+			Name:    "Pattern matching an unknown extension.",
+			Args:    []string{"--config-dir=./fixtures/conf.d"},
+			Pattern: "*.txt",
+			Validation: func(t *testing.T, app *App, err error) {
+				configErr, ok := err.(*ConfigError)
+				if assert.True(t, ok, "Error should be a *ConfigError.") {
+					assert.Equal(t, ErrConfigTypeUnknown, configErr.Err)
+				}
+			},
+		},
+		testConfigDirectory{
+			Name: "Path is not a directory.",
+			Args: []string{"--config-dir=./fixtures/basic_test.toml"},
+			Validation: func(t *testing.T, app *App, err error) {
+				configErr, ok := err.(*ConfigError)
+				if assert.True(t, ok, "Error should be a *ConfigError.") {
+					assert.Equal(t, ErrNotDirectory, configErr.Err)
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			testString, testInt, labels = "", 0, nil
+			app := NewApp()
+			app.Silent = true
+			app.Command = &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&StringVariable{
+						Name:        "teststring",
+						Destination: &testString,
+					},
+					&IntVariable{
+						Name:        "testint",
+						Destination: &testInt,
+					},
+					&StringMapVariable{
+						Name:        "test-labels",
+						Destination: &labels,
+					},
+					&ConfigVariable{
+						StringVariable: &StringVariable{
+							Name: "config-dir",
+						},
+						Type:    ConfigDirectory,
+						Pattern: test.Pattern,
+					},
+				},
+			}
+			err := app.RunE(append([]string{"path_to_exec"}, test.Args...))
+			test.Validation(t, app, err)
+		})
+	}
+}
+
+type testEnvNames struct {
+	Name       string
+	EnvVars    []envVar
+	Args       []string
+	Setup      func(*App)
+	Validation func(*testing.T, *App)
+}
+
+func TestEnvNames(t *testing.T) {
+	var testString string
+	var servePort int
+	var workerPort int
+
+	tests := []testEnvNames{
+		testEnvNames{
+			Name: "Prefix.",
+			EnvVars: []envVar{
+				envVar{"TEST_VALUE", "unprefixed"},
+				envVar{"MYAPP_TEST_VALUE", "prefixed"},
+			},
+			Setup: func(app *App) {
+				app.EnvPrefix = "MYAPP_"
+			},
+			Validation: func(t *testing.T, app *App) {
+				assert.Equal(t, "prefixed", testString)
+				settings := app.settingsMap.MainMap["main"]["test-value"]
+				if assert.Len(t, settings, 1) {
+					assert.Equal(t, "MYAPP_TEST_VALUE", settings[0].SettingName, "The setting should use the env name.")
+				}
+			},
+		},
+		testEnvNames{
+			Name: "Command path.",
+			Args: []string{"serve"},
+			EnvVars: []envVar{
+				envVar{"PORT", "1"},
+				envVar{"MYAPP_SERVE_PORT", "2"},
+				envVar{"MYAPP_WORKER_PORT", "3"},
+				envVar{"MYAPP_TEST_VALUE", "main"},
+			},
+			Setup: func(app *App) {
+				app.EnvPrefix = "MYAPP_"
+				app.EnvCommandPath = true
+			},
+			Validation: func(t *testing.T, app *App) {
+				assert.Equal(t, "main", testString, "The main command should not be in the name.")
+				assert.Equal(t, 2, servePort)
+				assert.Equal(t, 0, workerPort, "Inactive commands should not be set.")
+			},
+		},
+		testEnvNames{
+			Name: "Env name and aliases.",
+			EnvVars: []envVar{
+				envVar{"TEST_VALUE", "generated"},
+				envVar{"OLD_VALUE", "alias"},
+			},
+			Setup: func(app *App) {
+				app.EnvPrefix = "MYAPP_"
+				variable := app.Command.Variables[0].(*StringVariable)
+				variable.EnvName = "NEW_VALUE"
+				variable.EnvAliases = []string{"OLDER_VALUE", "OLD_VALUE"}
+			},
+			Validation: func(t *testing.T, app *App) {
+				assert.Equal(t, "alias", testString)
+				assert.Equal(t, []string{"NEW_VALUE", "OLDER_VALUE", "OLD_VALUE"}, app.getEnvNames(app.Command, app.Command.Variables[0]))
+				settings := app.settingsMap.MainMap["main"]["test-value"]
+				if assert.Len(t, settings, 1) {
+					assert.Equal(t, "OLD_VALUE", settings[0].SettingName)
+				}
+			},
+		},
+		testEnvNames{
+			Name: "Config path from a prefixed name.",
+			EnvVars: []envVar{
+				envVar{"MYAPP_CONFIG", "./fixtures/prefixed_test.env"},
+				envVar{"CONFIG", "./fixtures/missing.env"},
+			},
+			Setup: func(app *App) {
+				app.EnvPrefix = "MYAPP_"
+				app.Command.Variables = append(app.Command.Variables, &ConfigVariable{
+					StringVariable: &StringVariable{
+						Name: "config",
+					},
+					Type: DotEnvConfig,
+				})
+			},
+			Validation: func(t *testing.T, app *App) {
+				assert.Equal(t, "from dotenv", testString, ".env files should use the prefixed names.")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			testString, servePort, workerPort = "", 0, 0
+			for _, envVar := range test.EnvVars {
+				os.Setenv(envVar.Key, envVar.Value)
+			}
+
+			app := NewApp()
+			app.Silent = true
+			app.Command = &Command{
+				Name: "main",
+				Variables: []Variable{
+					&StringVariable{
+						Name:        "test-value",
+						Destination: &testString,
+					},
+				},
+				Subcommands: []*Command{
+					&Command{
+						Name: "serve",
+						Variables: []Variable{
+							&IntVariable{
+								Name:        "port",
+								Destination: &servePort,
+							},
+						},
+					},
+					&Command{
+						Name: "worker",
+						Variables: []Variable{
+							&IntVariable{
+								Name:        "port",
+								Destination: &workerPort,
+							},
+						},
+					},
+				},
+			}
+			test.Setup(app)
+			assert.NoError(t, app.RunE(append([]string{"path_to_exec"}, test.Args...)))
+			test.Validation(t, app)
+
+			for _, envVar := range test.EnvVars {
+				assert.NoError(t, os.Unsetenv(envVar.Key), "Should not error while unsetting the env var.")
+			}
+		})
+	}
+}
+
+type testAliases struct {
+	Name       string
+	Args       []string
+	EnvVars    []envVar
+	Validation func(*testing.T, *App, error)
+}
+
+func TestAliases(t *testing.T) {
+	var port int
+	var verbose bool
+	var tags []string
+	var newName string
+
+	tests := []testAliases{
+		testAliases{
+			Name: "Short flags.",
+			Args: []string{"-p=8080", "-v", "-t=a", "--tag=b"},
+			Validation: func(t *testing.T, app *App, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, 8080, port)
+				assert.True(t, verbose)
+				assert.Equal(t, []string{"a", "b"}, tags, "Short and long flags should share values.")
+				settings := app.settingsMap.MainMap["basic"]["port"]
+				if assert.Len(t, settings, 1) {
+					assert.Equal(t, "-p", settings[0].Alias)
+				}
+				settings = app.settingsMap.MainMap["basic"]["tag"]
+				if assert.Len(t, settings, 1) {
+					assert.Equal(t, "", settings[0].Alias, "The Name was used.")
+				}
+			},
+		},
+		testAliases{
+			Name: "Long aliases.",
+			Args: []string{"--listen-port=1"},
+			Validation: func(t *testing.T, app *App, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, 1, port)
+				settings := app.settingsMap.MainMap["basic"]["port"]
+				if assert.Len(t, settings, 1) {
+					assert.Equal(t, "--listen-port", settings[0].Alias)
+				}
+			},
+		},
+		testAliases{
+			Name: "Environment aliases.",
+			EnvVars: []envVar{
+				envVar{"LISTEN_PORT", "5"},
+			},
+			Validation: func(t *testing.T, app *App, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, 5, port)
+			},
+		},
+		testAliases{
+			Name: "Config aliases.",
+			Args: []string{"--config=./fixtures/alias_test.toml"},
+			Validation: func(t *testing.T, app *App, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, "from alias", newName)
+				settings := app.settingsMap.MainMap["basic"]["new-name"]
+				if assert.Len(t, settings, 1) {
+					assert.Equal(t, "old-name", settings[0].Alias)
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			port, verbose, tags, newName = 0, false, nil, ""
+			for _, envVar := range test.EnvVars {
+				os.Setenv(envVar.Key, envVar.Value)
+			}
+
+			app := NewApp()
+			app.Silent = true
+			app.Command = &Command{
+				Name: "basic",
+				Variables: []Variable{
+					&IntVariable{
+						Name:        "port",
+						Destination: &port,
+						VariableOptions: VariableOptions{
+							Aliases: []string{"listen-port"},
+							Short:   "p",
+						},
+					},
+					&BoolVariable{
+						Name:        "verbose",
+						Destination: &verbose,
+						VariableOptions: VariableOptions{
+							Short: "v",
+						},
+					},
+					&StringSliceVariable{
+						Name:        "tag",
+						Destination: &tags,
+						VariableOptions: VariableOptions{
+							Short: "t",
+						},
+					},
+					&StringVariable{
+						Name:        "new-name",
+						Destination: &newName,
+						VariableOptions: VariableOptions{
+							Aliases: []string{"old-name"},
+						},
+					},
+					&ConfigVariable{
+						StringVariable: &StringVariable{
+							Name: "config",
+						},
+						Type: TomlConfig,
+					},
+				},
+			}
+			err := app.RunE(append([]string{"path_to_exec"}, test.Args...))
+			test.Validation(t, app, err)
+
+			for _, envVar := range test.EnvVars {
+				assert.NoError(t, os.Unsetenv(envVar.Key), "Should not error while unsetting the env var.")
+			}
+		})
+	}
+
+	app := NewApp()
+	app.Silent = true
+	app.Command = &Command{
+		Name: "basic",
+		Variables: []Variable{
+			&IntVariable{
+				Name:        "port",
+				Destination: &port,
+				VariableOptions: VariableOptions{
+					Short: "p",
+				},
+			},
+			&StringVariable{
+				Name:        "path",
+				Destination: &newName,
+				VariableOptions: VariableOptions{
+					Short: "p",
+				},
+			},
+		},
+	}
+	err := app.RunE([]string{"path_to_exec"})
+	assert.Equal(t, &DuplicateVariableError{Command: "basic", Variable: "p"}, err, "Short flags should not be reused.")
+}
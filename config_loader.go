@@ -0,0 +1,212 @@
+package unpuzzled
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/Jeffail/gabs"
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v2"
+)
+
+// First ParsingType available for custom config loaders, ex.
+// const HclConfig = unpuzzled.CustomConfig + iota
+const CustomConfig ParsingType = 100
+
+// A configuration file format. Register it with app.RegisterConfigLoader, and set the same ParsingType on a ConfigVariable.
+type ConfigLoader interface {
+	// Name of the source used in the help text and the override output, ex. "Toml Config"
+	Name() string
+	// Parse the contents of a configuration file.
+	Load(io.Reader) (ConfigSource, error)
+}
+
+// A loaded configuration file.
+type ConfigSource interface {
+	// Get a value by the dotted command path and variable name, ex. "main.subcommand.variable".
+	// Return nil if the value is not set. Tables should be returned as map[string]interface{}, and arrays as []interface{}.
+	Lookup(path string) (interface{}, error)
+}
+
+// Optionally implemented by a ConfigLoader, the file extensions it loads from a ConfigDirectory, ex. ".toml"
+type ConfigExtensions interface {
+	Extensions() []string
+}
+
+// Finds the loader for a config type, or for a file extension in a config directory.
+type configLoaderLookup interface {
+	getConfigLoader(ParsingType) ConfigLoader
+	getExtensionLoader(string) ConfigLoader
+}
+
+// Get the loader for a file extension, types are checked in order so the result is consistent.
+func findExtensionLoader(loaders map[ParsingType]ConfigLoader, ext string) ConfigLoader {
+	types := make([]ParsingType, 0, len(loaders))
+	for t := range loaders {
+		types = append(types, t)
+	}
+	sort.Sort(parsingTypes(types))
+	for _, t := range types {
+		extensions, ok := loaders[t].(ConfigExtensions)
+		if !ok {
+			continue
+		}
+		for _, extension := range extensions.Extensions() {
+			if strings.EqualFold(extension, ext) {
+				return loaders[t]
+			}
+		}
+	}
+	return nil
+}
+
+type parsingTypes []ParsingType
+
+func (p parsingTypes) Len() int           { return len(p) }
+func (p parsingTypes) Less(i, j int) bool { return p[i] < p[j] }
+func (p parsingTypes) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+var defaultConfigLoaders = map[ParsingType]ConfigLoader{
+	JsonConfig:   &jsonLoader{},
+	TomlConfig:   &tomlLoader{},
+	YamlConfig:   &yamlLoader{},
+	DotEnvConfig: &dotEnvLoader{},
+}
+
+// Finds the built in loaders, used when a config is parsed without an App.
+type defaultLoaders struct{}
+
+func (defaultLoaders) getConfigLoader(t ParsingType) ConfigLoader {
+	return defaultConfigLoaders[t]
+}
+
+func (defaultLoaders) getExtensionLoader(ext string) ConfigLoader {
+	return findExtensionLoader(defaultConfigLoaders, ext)
+}
+
+type tomlLoader struct{}
+
+func (t *tomlLoader) Name() string {
+	return ParsingTypeStringMap[TomlConfig]
+}
+
+func (t *tomlLoader) Extensions() []string {
+	return []string{".toml"}
+}
+
+func (t *tomlLoader) Load(r io.Reader) (ConfigSource, error) {
+	tree, err := toml.LoadReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &tomlConfig{
+		tree: tree,
+	}, nil
+}
+
+type tomlConfig struct {
+	tree *toml.Tree
+}
+
+func (t *tomlConfig) Lookup(path string) (interface{}, error) {
+	value := t.tree.Get(path)
+	// tables are used by map variables.
+	if tree, ok := value.(*toml.Tree); ok {
+		return tree.ToMap(), nil
+	}
+	return value, nil
+}
+
+type jsonLoader struct{}
+
+func (j *jsonLoader) Name() string {
+	return ParsingTypeStringMap[JsonConfig]
+}
+
+func (j *jsonLoader) Extensions() []string {
+	return []string{".json"}
+}
+
+func (j *jsonLoader) Load(r io.Reader) (ConfigSource, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	container, err := gabs.ParseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonConfig{
+		container: container,
+	}, nil
+}
+
+type jsonConfig struct {
+	container *gabs.Container
+}
+
+func (j *jsonConfig) Lookup(path string) (interface{}, error) {
+	return j.container.Path(path).Data(), nil
+}
+
+type yamlLoader struct{}
+
+func (y *yamlLoader) Name() string {
+	return ParsingTypeStringMap[YamlConfig]
+}
+
+func (y *yamlLoader) Extensions() []string {
+	return []string{".yaml", ".yml"}
+}
+
+func (y *yamlLoader) Load(r io.Reader) (ConfigSource, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	return &yamlConfig{
+		tree: normalizeYaml(tree).(map[string]interface{}),
+	}, nil
+}
+
+type yamlConfig struct {
+	tree map[string]interface{}
+}
+
+func (y *yamlConfig) Lookup(path string) (interface{}, error) {
+	var current interface{} = y.tree
+	for _, key := range strings.Split(path, ".") {
+		node, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		if current, ok = node[key]; !ok {
+			return nil, nil
+		}
+	}
+	return current, nil
+}
+
+// yaml decodes maps with interface{} keys, convert them to string keys to match the other config types.
+func normalizeYaml(value interface{}) interface{} {
+	switch node := value.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(node))
+		for k, v := range node {
+			out[fmt.Sprintf("%v", k)] = normalizeYaml(v)
+		}
+		return out
+	case []interface{}:
+		for i, v := range node {
+			node[i] = normalizeYaml(v)
+		}
+	}
+	return value
+}
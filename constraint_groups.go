@@ -0,0 +1,191 @@
+package unpuzzled
+
+import (
+	"fmt"
+	"strings"
+)
+
+type GroupType int
+
+const (
+	// At most one of the variables can be set.
+	ExclusiveGroup GroupType = iota
+	// Either all of the variables are set, or none of them.
+	TogetherGroup
+	// At least one of the variables must be set.
+	AtLeastOneGroup
+	// All of the variables must be set if the If variable is set.
+	RequiredIfGroup
+)
+
+// A rule between the variables of a single command, set in Command.ConstraintGroups.
+// Only values set from a source count, defaults are ignored.
+type ConstraintGroup struct {
+	Type GroupType
+	// Names of variables in the same command.
+	Variables []string
+	// For RequiredIfGroup, the variable that makes the Variables required when it's set.
+	If string
+}
+
+// At most one of the variables can be set, ex. MutuallyExclusive("token", "password")
+func MutuallyExclusive(names ...string) *ConstraintGroup {
+	return &ConstraintGroup{Type: ExclusiveGroup, Variables: names}
+}
+
+// Either all of the variables are set, or none of them, ex. RequiredTogether("cert", "key")
+func RequiredTogether(names ...string) *ConstraintGroup {
+	return &ConstraintGroup{Type: TogetherGroup, Variables: names}
+}
+
+// At least one of the variables must be set, ex. AtLeastOne("token", "password")
+func AtLeastOne(names ...string) *ConstraintGroup {
+	return &ConstraintGroup{Type: AtLeastOneGroup, Variables: names}
+}
+
+// The required variables must be set if the variable is set, ex. RequiredIf("tls", "cert", "key")
+func RequiredIf(name string, required ...string) *ConstraintGroup {
+	return &ConstraintGroup{Type: RequiredIfGroup, Variables: required, If: name}
+}
+
+// The rule, used in errors and the help text.
+func (g *ConstraintGroup) String() string {
+	names := strings.Join(g.Variables, ", ")
+	switch g.Type {
+	case ExclusiveGroup:
+		return fmt.Sprintf("Only one of %s can be set.", names)
+	case TogetherGroup:
+		return fmt.Sprintf("%s must be set together.", names)
+	case AtLeastOneGroup:
+		return fmt.Sprintf("At least one of %s must be set.", names)
+	case RequiredIfGroup:
+		return fmt.Sprintf("%s required when %s is set.", names, g.If)
+	}
+	return fmt.Sprintf("Unknown group type %d: %s", g.Type, names)
+}
+
+// Every variable named by the group.
+func (g *ConstraintGroup) names() []string {
+	if g.Type == RequiredIfGroup {
+		return append([]string{g.If}, g.Variables...)
+	}
+	return g.Variables
+}
+
+// A value that's part of a broken ConstraintGroup.
+type GroupValue struct {
+	Variable    string
+	Value       interface{}
+	Source      ParsingType
+	SettingName string
+	// The name of the source, set for sources registered with the App.
+	SourceName string
+}
+
+func (g *GroupValue) String() string {
+	if g.SettingName != "" {
+		return fmt.Sprintf("%s from %s (%s)", g.Variable, sourceString(g.Source, g.SourceName), g.SettingName)
+	}
+	return fmt.Sprintf("%s from %s", g.Variable, sourceString(g.Source, g.SourceName))
+}
+
+// Ensure every variable in a ConstraintGroup is a variable in the same command.
+func (c *Command) checkConstraintGroups() error {
+	var err error
+	c.loopActiveCommands(func(command *Command) {
+		variableMap := command.GetVariableMap()
+		for _, group := range command.ConstraintGroups {
+			for _, name := range group.names() {
+				if _, ok := variableMap[name]; !ok && err == nil {
+					err = &UnknownGroupVariableError{
+						Command:  command.GetExpandedName(),
+						Variable: name,
+					}
+				}
+			}
+		}
+	})
+	return err
+}
+
+// Check the ConstraintGroups of every active command against the set values.
+func (a *App) checkConstraintGroups() ConstraintGroupErrors {
+	var groupErrors ConstraintGroupErrors
+	a.Command.loopActiveCommands(func(command *Command) {
+		path := command.GetExpandedName()
+		for _, group := range command.ConstraintGroups {
+			set := make(map[string]*GroupValue)
+			var setValues []*GroupValue
+			for _, name := range group.names() {
+				if value := a.getGroupValue(path, name); value != nil {
+					set[name] = value
+					setValues = append(setValues, value)
+				}
+			}
+
+			var missing []string
+			for _, name := range group.Variables {
+				if set[name] == nil {
+					missing = append(missing, name)
+				}
+			}
+			failed := false
+			switch group.Type {
+			case ExclusiveGroup:
+				failed = len(setValues) > 1
+				missing = nil
+			case TogetherGroup:
+				failed = len(setValues) > 0 && len(missing) > 0
+			case AtLeastOneGroup:
+				failed = len(setValues) == 0
+			case RequiredIfGroup:
+				failed = set[group.If] != nil && len(missing) > 0
+			}
+			if failed {
+				groupErrors = append(groupErrors, &ConstraintGroupError{
+					Command: path,
+					Group:   group,
+					Set:     setValues,
+					Missing: missing,
+				})
+			}
+		}
+	})
+	return groupErrors
+}
+
+// The last value set for a variable, ignoring defaults.
+func (a *App) getGroupValue(path string, name string) *GroupValue {
+	settings := a.settingsMap.MainMap[path][name]
+	for i := len(settings) - 1; i >= 0; i-- {
+		if settings[i].Source == DefaultValue {
+			continue
+		}
+		value := displayedValue(settings[i].Value)
+		if settings[i].Sensitive {
+			value = maskedValue
+		}
+		return &GroupValue{
+			Variable:    name,
+			Value:       value,
+			Source:      settings[i].Source,
+			SourceName:  a.sourceNames[settings[i].Source],
+			SettingName: settings[i].SettingName,
+		}
+	}
+	return nil
+}
+
+// Print every broken ConstraintGroup, grouped by command.
+func (a *App) PrintConstraintGroupErrors() {
+	if a.Silent {
+		return
+	}
+	if a.constraintGroupErrors == nil {
+		panic("There are no constraint group errors.")
+	}
+	a.printCommandErrors("Conflicting Variables:", `{{ noEscape $err.Group.String }}
+{{ range $j, $v := $err.Set }}    {{ green "--"}}{{ green $v.Variable }} = {{ noEscape (printf "%v" $v.Value) }} from {{ sourceString $v.Source }}{{ if gt (len $v.SettingName) 0 }} ({{ noEscape $v.SettingName }}){{ end }}
+{{ end }}{{ range $j, $name := $err.Missing }}    {{ green "--"}}{{ green $name }} : {{ red "not set" }}
+{{ end }}`, a.constraintGroupErrors)
+}
@@ -0,0 +1,83 @@
+package unpuzzled
+
+import (
+	"context"
+)
+
+// Context is passed to Command.ActionE, with the state of the current run.
+type Context struct {
+	// The app being run.
+	App *App
+	// All active commands, from the main command to the command being run.
+	Commands []*Command
+	// Positional arguments left over after parsing the flags of the command being run.
+	Args []string
+
+	ctx         context.Context
+	settingsMap *mappedSettings
+}
+
+func newContext(ctx context.Context, a *App) *Context {
+	finalCommand := a.activeCommands[len(a.activeCommands)-1]
+	var args []string
+	if finalCommand.flagSet != nil {
+		args = finalCommand.flagSet.Args()
+	}
+	return &Context{
+		App:         a,
+		Commands:    a.activeCommands,
+		Args:        args,
+		ctx:         ctx,
+		settingsMap: a.settingsMap,
+	}
+}
+
+// The context.Context passed to App.RunContext, used for cancellation.
+func (c *Context) Context() context.Context {
+	return c.ctx
+}
+
+// The command being run, the last of the active commands.
+func (c *Context) Command() *Command {
+	return c.Commands[len(c.Commands)-1]
+}
+
+// Get the resolved value of a variable, and the source it was set from, the last source for merged values.
+// The command being run is checked first, followed by its parent commands.
+func (c *Context) Lookup(name string) (interface{}, ParsingType, bool) {
+	for i := len(c.Commands) - 1; i >= 0; i-- {
+		if value, source, ok := c.LookupCommand(c.Commands[i].GetExpandedName(), name); ok {
+			return value, source, true
+		}
+	}
+	return nil, 0, false
+}
+
+// Get the resolved value of a variable for a single command, by the expanded command name (ex. main.sub1).
+func (c *Context) LookupCommand(commandPath string, name string) (interface{}, ParsingType, bool) {
+	settings := c.settingsMap.MainMap[commandPath][name]
+	if len(settings) == 0 {
+		return nil, 0, false
+	}
+	activeSetting := settings[len(settings)-1]
+	if value, ok := c.getMergedValue(commandPath, name); ok {
+		return value, activeSetting.Source, true
+	}
+	return activeSetting.Value, activeSetting.Source, true
+}
+
+// Map variables and slices with AppendSources combine every setting, so the value is read from the destination instead
+// of the last setting.
+func (c *Context) getMergedValue(commandPath string, name string) (interface{}, bool) {
+	for _, command := range c.Commands {
+		if command.GetExpandedName() != commandPath {
+			continue
+		}
+		variable, ok := command.GetVariableMap()[name].(mergingVariable)
+		if !ok || !variable.mergesSources() {
+			return nil, false
+		}
+		return getDestinationValue(variable)
+	}
+	return nil, false
+}
@@ -0,0 +1,133 @@
+package unpuzzled
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var dotEnvKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// Loads .env files, used by ConfigVariables with the DotEnvConfig type.
+// Values are looked up by the environment variable name, ex. "TEST_VALUE".
+type dotEnvLoader struct{}
+
+func (d *dotEnvLoader) Name() string {
+	return ParsingTypeStringMap[DotEnvConfig]
+}
+
+func (d *dotEnvLoader) Load(r io.Reader) (ConfigSource, error) {
+	values, err := parseDotEnv(r)
+	if err != nil {
+		return nil, err
+	}
+	return dotEnvConfig(values), nil
+}
+
+type dotEnvConfig map[string]string
+
+func (d dotEnvConfig) Lookup(name string) (interface{}, error) {
+	if value, ok := d[name]; ok {
+		return value, nil
+	}
+	return nil, nil
+}
+
+// Parse a .env file. Supports comments, the "export" prefix, single quoted values that are used as is,
+// double quoted values with escapes, multi-line quoted values, and $VAR or ${VAR} interpolation from
+// earlier values in the file or the environment.
+func parseDotEnv(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	lookup := func(name string) string {
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return os.Getenv(name)
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Line %d: expected KEY=VALUE.", lineNumber)
+		}
+		key := strings.TrimSpace(parts[0])
+		if !dotEnvKeyRegexp.MatchString(key) {
+			return nil, fmt.Errorf("Line %d: invalid name %q.", lineNumber, key)
+		}
+		value := strings.TrimSpace(parts[1])
+
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			quote := value[0]
+			startLine := lineNumber
+			// keep reading lines until the closing quote.
+			for !hasClosingQuote(value, quote) {
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("Line %d: missing closing quote.", startLine)
+				}
+				lineNumber++
+				value += "\n" + scanner.Text()
+			}
+			end := closingQuoteIndex(value, quote)
+			if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, fmt.Errorf("Line %d: unexpected characters after the closing quote.", lineNumber)
+			}
+			value = value[1:end]
+			if quote == '"' {
+				value = expandDotEnvValue(unescapeDotEnvValue(value), lookup)
+			}
+		} else {
+			if index := strings.Index(value, " #"); index >= 0 {
+				value = strings.TrimSpace(value[:index])
+			}
+			value = expandDotEnvValue(value, lookup)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func hasClosingQuote(value string, quote byte) bool {
+	return closingQuoteIndex(value, quote) > 0
+}
+
+// Index of the closing quote, skipping escaped quotes in double quoted values. -1 if it's not found.
+func closingQuoteIndex(value string, quote byte) int {
+	for i := 1; i < len(value); i++ {
+		if quote == '"' && value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+var dotEnvEscapes = strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`)
+
+func unescapeDotEnvValue(value string) string {
+	// keep escaped dollar signs for expandDotEnvValue.
+	value = strings.Replace(value, `\$`, "\x00", -1)
+	return dotEnvEscapes.Replace(value)
+}
+
+// Expand $VAR and ${VAR}, escaped dollar signs are kept as is.
+func expandDotEnvValue(value string, lookup func(string) string) string {
+	expanded := os.Expand(value, lookup)
+	return strings.Replace(expanded, "\x00", "$", -1)
+}
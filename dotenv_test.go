@@ -0,0 +1,126 @@
+package unpuzzled
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testParseDotEnv struct {
+	Name     string
+	Input    string
+	Expected map[string]string
+	IsError  bool
+}
+
+func TestParseDotEnv(t *testing.T) {
+	os.Setenv("DOTENV_FROM_ENV", "env")
+	defer os.Unsetenv("DOTENV_FROM_ENV")
+
+	tests := []testParseDotEnv{
+		testParseDotEnv{
+			Name:     "Comments and export.",
+			Input:    "# comment\n\nexport A=1\nB = two words # comment\n",
+			Expected: map[string]string{"A": "1", "B": "two words"},
+		},
+		testParseDotEnv{
+			Name:     "Quotes.",
+			Input:    "A='single $B # kept'\nB=\"double \\\"quoted\\\"\\n\" # comment\nC=\"\"\n",
+			Expected: map[string]string{"A": "single $B # kept", "B": "double \"quoted\"\n", "C": ""},
+		},
+		testParseDotEnv{
+			Name:     "Multi-line values.",
+			Input:    "A=\"first\nsecond\"\nB='one\ntwo'\n",
+			Expected: map[string]string{"A": "first\nsecond", "B": "one\ntwo"},
+		},
+		testParseDotEnv{
+			Name:     "Interpolation.",
+			Input:    "A=a\nB=${A}-$A\nC=\"$DOTENV_FROM_ENV \\$A\"\nD=$MISSING_DOTENV_VALUE\n",
+			Expected: map[string]string{"A": "a", "B": "a-a", "C": "env $A", "D": ""},
+		},
+		testParseDotEnv{
+			Name:    "Missing equals.",
+			Input:   "A\n",
+			IsError: true,
+		},
+		testParseDotEnv{
+			Name:    "Invalid name.",
+			Input:   "A-B=1\n",
+			IsError: true,
+		},
+		testParseDotEnv{
+			Name:    "Missing closing quote.",
+			Input:   "A=\"open\nB=1\n",
+			IsError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			values, err := parseDotEnv(strings.NewReader(test.Input))
+			if test.IsError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.Expected, values)
+			}
+		})
+	}
+}
+
+func TestDotEnvConfig(t *testing.T) {
+	config := &fullTestConfig{}
+	os.Setenv("TEST_INT", "7")
+	defer os.Unsetenv("TEST_INT")
+
+	app := NewApp()
+	app.Silent = true
+	app.Command = &Command{
+		Name: "basic",
+		Variables: []Variable{
+			&StringVariable{
+				Name:        "test-value",
+				Destination: &config.TestString,
+			},
+			&IntVariable{
+				Name:        "test-int",
+				Destination: &config.TestInt,
+			},
+			&BoolVariable{
+				Name:        "test-bool",
+				Destination: &config.TestBool,
+			},
+			&DurationVariable{
+				Name:        "test-duration",
+				Destination: &config.TestDuration,
+			},
+			&ConfigVariable{
+				StringVariable: &StringVariable{
+					Name:    "env-file",
+					Default: "./fixtures/basic_test.env",
+				},
+				Type: DotEnvConfig,
+			},
+		},
+	}
+	assert.NoError(t, app.RunE([]string{"path_to_exec"}))
+	assert.Equal(t, &fullTestConfig{
+		TestString:   "hello world",
+		TestInt:      7,
+		TestBool:     true,
+		TestDuration: time.Minute,
+	}, config, "The environment should override the .env file.")
+
+	settings := app.settingsMap.MainMap["basic"]["test-value"]
+	if assert.Len(t, settings, 1) {
+		assert.Equal(t, DotEnvConfig, settings[0].Source)
+		assert.Equal(t, "./fixtures/basic_test.env", settings[0].SettingName)
+	}
+	settings = app.settingsMap.MainMap["basic"]["test-int"]
+	if assert.Len(t, settings, 2) {
+		assert.Equal(t, DotEnvConfig, settings[0].Source)
+		assert.Equal(t, EnvironmentVariables, settings[1].Source)
+	}
+}
@@ -0,0 +1,290 @@
+package unpuzzled
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var (
+	// Returned by RunE when the arguments contain one of the App.HelpCommands.
+	ErrHelpRequested = errors.New("Help text requested.")
+	// Returned by RunE when it's called without any arguments.
+	ErrNoArguments = errors.New("Arguments must be at least 1, please run with app.Run(os.Args).")
+	// Returned by RunE when the App has no Command attached.
+	ErrNoCommand = errors.New("No command attached to the app!")
+	// Returned by RunE when the App has no ParsingOrder.
+	ErrNoParsingOrder = errors.New("No parsing order! Use unpuzzled.NewApp when creating an application.")
+	// Returned by Reload and Watch when the app hasn't been run.
+	ErrNotParsed = errors.New("The app must be run before it can be reloaded.")
+	// Set on a ReloadError when the changed variable isn't Reloadable.
+	ErrNotReloadable = errors.New("Variable is not reloadable, restart to apply the change.")
+	// Returned by Watch when the interval is negative.
+	ErrInvalidInterval = errors.New("The watch interval can't be negative.")
+)
+
+// Errors returned from Command.ActionE that implement ExitCoder set the exit code used by App.Run.
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
+// An error with an exit code, returned from Command.ActionE.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+// Create an error that makes App.Run exit with the given code.
+func NewExitError(err error, code int) *ExitError {
+	return &ExitError{
+		Code: code,
+		Err:  err,
+	}
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("Exit code %d.", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Returned when one or more required variables are not set from any source.
+// Variables is keyed by the expanded command name.
+type MissingRequiredError struct {
+	Variables map[string][]Variable
+}
+
+func (m *MissingRequiredError) Error() string {
+	count := 0
+	for _, variables := range m.Variables {
+		count += len(variables)
+	}
+	return fmt.Sprintf("Missing %d required variable(s).", count)
+}
+
+// Returned when a value from any source can't be parsed into a variable.
+type ParseError struct {
+	Source   ParsingType
+	Command  string
+	Variable string
+	RawValue string
+	Err      error
+	// The name of the source, set for sources registered with the App.
+	SourceName string
+}
+
+func (p *ParseError) Error() string {
+	if p.Variable == "" {
+		return fmt.Sprintf("Failed to parse %s for command %s: %v", sourceString(p.Source, p.SourceName), p.Command, p.Err)
+	}
+	return fmt.Sprintf("Failed to parse %s.%s from %s (%q): %v", p.Command, p.Variable, sourceString(p.Source, p.SourceName), p.RawValue, p.Err)
+}
+
+func (p *ParseError) getCommand() string {
+	return p.Command
+}
+
+// Every value that failed to parse in a single run, returned by RunE.
+type ParseErrors []*ParseError
+
+func (p ParseErrors) Error() string {
+	messages := make([]string, len(p))
+	for i, err := range p {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("Failed to parse %d value(s): %s", len(p), strings.Join(messages, "; "))
+}
+
+// Returned when a set value fails a variable's Constraints or Validate func.
+type ValidationError struct {
+	Command  string
+	Variable string
+	Value    interface{}
+	Source   ParsingType
+	Err      error
+	// The name of the source, set for sources registered with the App.
+	SourceName string
+}
+
+func (v *ValidationError) Error() string {
+	return fmt.Sprintf("Invalid value for %s.%s from %s (%v): %v", v.Command, v.Variable, sourceString(v.Source, v.SourceName), v.Value, v.Err)
+}
+
+func (v *ValidationError) getCommand() string {
+	return v.Command
+}
+
+// Every value that failed validation in a single run, returned by RunE.
+type ValidationErrors []*ValidationError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, err := range v {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("Failed to validate %d value(s): %s", len(v), strings.Join(messages, "; "))
+}
+
+// Returned when a command has two variables with the same name.
+type DuplicateVariableError struct {
+	Command  string
+	Variable string
+}
+
+func (d *DuplicateVariableError) Error() string {
+	return fmt.Sprintf("Duplicate variables seen with the same name: %s.%s", d.Command, d.Variable)
+}
+
+// Returned when a variable's ReplacedBy isn't a variable in the same command.
+type UnknownReplacementError struct {
+	Command    string
+	Variable   string
+	ReplacedBy string
+}
+
+func (u *UnknownReplacementError) Error() string {
+	return fmt.Sprintf("Variable %s.%s is replaced by an unknown variable: %s", u.Command, u.Variable, u.ReplacedBy)
+}
+
+// Returned when a variable is replaced by a variable of a different type.
+type ReplacementTypeError struct {
+	Command         string
+	Variable        string
+	Type            string
+	ReplacedBy      string
+	ReplacementType string
+}
+
+func (r *ReplacementTypeError) Error() string {
+	return fmt.Sprintf("Variable %s.%s (%s) is replaced by %s, which has a different type (%s)", r.Command, r.Variable, r.Type, r.ReplacedBy, r.ReplacementType)
+}
+
+// Returned when a constraint can't check the type of its variable, ex. Min on a string variable.
+type ConstraintTypeError struct {
+	Command    string
+	Variable   string
+	Constraint string
+	Type       string
+}
+
+func (c *ConstraintTypeError) Error() string {
+	return fmt.Sprintf("Constraint %q on %s.%s can't check values of type %s", c.Constraint, c.Command, c.Variable, c.Type)
+}
+
+// Returned when a ConstraintGroup names a variable that isn't in the command.
+type UnknownGroupVariableError struct {
+	Command  string
+	Variable string
+}
+
+func (u *UnknownGroupVariableError) Error() string {
+	return fmt.Sprintf("Constraint group in %s uses an unknown variable: %s", u.Command, u.Variable)
+}
+
+// Returned when the values set for a command break one of its ConstraintGroups.
+type ConstraintGroupError struct {
+	Command string
+	Group   *ConstraintGroup
+	// The variables in the group that are set, with the source of each value.
+	Set []*GroupValue
+	// The variables in the group that must be set, but aren't.
+	Missing []string
+}
+
+func (c *ConstraintGroupError) Error() string {
+	set := make([]string, len(c.Set))
+	for i, value := range c.Set {
+		set[i] = value.String()
+	}
+	message := fmt.Sprintf("%s: %s", c.Command, c.Group.String())
+	if len(set) > 0 {
+		message += fmt.Sprintf(" Set: %s.", strings.Join(set, ", "))
+	}
+	if len(c.Missing) > 0 {
+		message += fmt.Sprintf(" Missing: %s.", strings.Join(c.Missing, ", "))
+	}
+	return message
+}
+
+func (c *ConstraintGroupError) getCommand() string {
+	return c.Command
+}
+
+// Every broken ConstraintGroup in a single run, returned by RunE.
+type ConstraintGroupErrors []*ConstraintGroupError
+
+func (c ConstraintGroupErrors) Error() string {
+	messages := make([]string, len(c))
+	for i, err := range c {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d constraint group(s) failed: %s", len(c), strings.Join(messages, "; "))
+}
+
+// A change rejected by App.Reload, the variable keeps its previous value.
+type ReloadError struct {
+	*Change
+	// ErrNotReloadable, or the error returned from the variable's OnChange.
+	Err error
+}
+
+func (r *ReloadError) Error() string {
+	return fmt.Sprintf("Rejected change to %s.%s from %s (%v -> %v): %v", r.Command, r.Variable, sourceString(r.Source, r.SourceName), r.OldValue, r.NewValue, r.Err)
+}
+
+// Every change rejected by a single reload, returned by Reload. The other changes are still applied.
+type ReloadErrors []*ReloadError
+
+func (r ReloadErrors) Error() string {
+	messages := make([]string, len(r))
+	for i, err := range r {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("Rejected %d change(s): %s", len(r), strings.Join(messages, "; "))
+}
+
+// Returned when a configuration file can't be read or parsed.
+type ConfigError struct {
+	Type     ParsingType
+	Variable string
+	Path     string
+	Err      error
+	// The name of the config type, set for loaders registered with the App.
+	TypeName string
+}
+
+func (c *ConfigError) Error() string {
+	return fmt.Sprintf("Failed to load %s from --%s=%s: %v", sourceString(c.Type, c.TypeName), c.Variable, c.Path, c.Err)
+}
+
+// The name of a source in errors, the name from the App if it's set.
+func sourceString(source ParsingType, name string) string {
+	if name != "" {
+		return name
+	}
+	return ParsingTypeStringMap[source]
+}
+
+// An error for a single command, printed grouped by command.
+type commandError interface {
+	error
+	getCommand() string
+}
+
+// Group a list of command errors by command path, ex. groupByCommand(ParseErrors{...})
+func groupByCommand(errs interface{}) map[string][]commandError {
+	list := reflect.ValueOf(errs)
+	outMap := make(map[string][]commandError)
+	for i := 0; i < list.Len(); i++ {
+		err := list.Index(i).Interface().(commandError)
+		outMap[err.getCommand()] = append(outMap[err.getCommand()], err)
+	}
+	return outMap
+}
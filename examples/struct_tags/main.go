@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/timjchin/unpuzzled"
+)
+
+type Config struct {
+	Port     int           `unpuzzled:"default=8080,short=p,desc=The port to listen on."`
+	Timeout  time.Duration `unpuzzled:"default=5s,desc=Request timeout."`
+	Token    string        `unpuzzled:"required,sensitive,env=API_TOKEN"`
+	Database struct {
+		Host string `unpuzzled:"default=localhost"`
+		Port int    `unpuzzled:"default=5432"`
+	}
+	Serve struct {
+		Workers int `unpuzzled:"default=4"`
+	} `unpuzzled:"command,desc=Start the server."`
+}
+
+func main() {
+	config := &Config{}
+	command, err := unpuzzled.CommandFromStruct("main", config)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	command.Usage = "An example application that builds its variables from a struct."
+	command.Action = func() {
+		fmt.Printf("Running main command: %+v\n", config)
+	}
+	command.Subcommands[0].Action = func() {
+		fmt.Printf("Starting %d workers on port %d.\n", config.Serve.Workers, config.Port)
+	}
+
+	app := unpuzzled.NewApp()
+	app.Command = command
+	app.Run(os.Args)
+}
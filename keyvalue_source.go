@@ -0,0 +1,197 @@
+package unpuzzled
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A key-value store used as a source, ex. Vault, Consul or etcd. Register it with app.RegisterKeyValueSource.
+// Keys are the dotted command path and variable name, ex. "main.subcommand.variable", values are parsed the same way as
+// environment variables.
+type KeyValueSource interface {
+	// Get the value for a key. Return false if the key isn't set.
+	Get(ctx context.Context, key string) (string, bool, error)
+}
+
+// Optionally implemented by a KeyValueSource, to get every value for a command in a single request.
+type KeyValueLister interface {
+	// Get every key and value where the key starts with the prefix, ex. "main.subcommand."
+	List(ctx context.Context, prefix string) (map[string]string, error)
+}
+
+type namedKeyValueSource struct {
+	name   string
+	source KeyValueSource
+}
+
+// Register a key-value store as the source for the ParsingType, ex. const VaultSource = unpuzzled.CustomConfig + iota
+// The name is used for the type in the help text, and as the setting name in the override output.
+// If the type isn't in the ParsingOrder, it's added before CliFlags.
+func (a *App) RegisterKeyValueSource(t ParsingType, name string, source KeyValueSource) {
+	if a.keyValueSources == nil {
+		a.keyValueSources = make(map[ParsingType]*namedKeyValueSource)
+	}
+	a.keyValueSources[t] = &namedKeyValueSource{name: name, source: source}
+	a.setSourceName(t, name)
+	a.addParsingType(t)
+}
+
+// loop through all active variables, set from the key-value store. Variables are looked up by their name, then aliases.
+func (c *Command) parseKeyValueSource(ctx context.Context, t ParsingType, named *namedKeyValueSource) ([]*activeSetting, ParseErrors) {
+	var allSettings []*activeSetting
+	var parseErrors ParseErrors
+	listed := make(map[string]map[string]string)
+	c.loopActiveVariables(func(command *Command, variable Variable) {
+		// config paths are resolved by the ConfigVariable.
+		if _, ok := variable.(*ConfigVariable); ok {
+			return
+		}
+		expandedName := command.GetExpandedName()
+		addError := func(variableName string, rawValue string, err error) {
+			parseErrors = append(parseErrors, &ParseError{
+				Source:   t,
+				Command:  expandedName,
+				Variable: variableName,
+				RawValue: rawValue,
+				Err:      err,
+			})
+		}
+
+		get := func(key string) (string, bool, error) {
+			return named.source.Get(ctx, key)
+		}
+		if lister, ok := named.source.(KeyValueLister); ok {
+			values, ok := listed[expandedName]
+			if !ok {
+				var err error
+				if values, err = lister.List(ctx, expandedName+"."); err != nil {
+					addError("", "", err)
+				}
+				listed[expandedName] = values
+			}
+			get = func(key string) (string, bool, error) {
+				value, found := values[key]
+				return value, found, nil
+			}
+		}
+
+		for _, name := range append([]string{variable.GetName()}, variable.options().Aliases...) {
+			value, found, err := get(expandedName + "." + name)
+			if err != nil {
+				addError(variable.GetName(), "", err)
+				return
+			}
+			if !found {
+				continue
+			}
+			val, err := variable.setEnv(value, "")
+			if err != nil {
+				addError(variable.GetName(), value, err)
+				return
+			}
+			alias := ""
+			if name != variable.GetName() {
+				alias = name
+			}
+			allSettings = append(allSettings, &activeSetting{
+				CommandPath:  expandedName,
+				VariableName: variable.GetName(),
+				Value:        val,
+				Source:       t,
+				SettingName:  named.name,
+				Alias:        alias,
+				Destination:  variable.GetDestination(),
+			})
+			return
+		}
+	})
+	return allSettings, parseErrors
+}
+
+// A KeyValueSource kept in memory, for tests and local development.
+type MemoryKeyValueSource struct {
+	mutex  sync.RWMutex
+	values map[string]string
+}
+
+func NewMemoryKeyValueSource(values map[string]string) *MemoryKeyValueSource {
+	m := &MemoryKeyValueSource{
+		values: make(map[string]string),
+	}
+	for key, value := range values {
+		m.values[key] = value
+	}
+	return m
+}
+
+func (m *MemoryKeyValueSource) Get(ctx context.Context, key string) (string, bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	value, found := m.values[key]
+	return value, found, nil
+}
+
+func (m *MemoryKeyValueSource) List(ctx context.Context, prefix string) (map[string]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	values := make(map[string]string)
+	for key, value := range m.values {
+		if strings.HasPrefix(key, prefix) {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+func (m *MemoryKeyValueSource) Set(key string, value string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.values[key] = value
+}
+
+func (m *MemoryKeyValueSource) Delete(key string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.values, key)
+}
+
+// A KeyValueSource backed by a directory with a file per key, ex. "main.port", like a mounted Kubernetes ConfigMap.
+// The trailing newline of each file is trimmed, and hidden files are skipped.
+type FileKeyValueSource struct {
+	Dir string
+}
+
+func (f *FileKeyValueSource) Get(ctx context.Context, key string) (string, bool, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(f.Dir, key))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimRight(string(contents), "\r\n"), true, nil
+}
+
+func (f *FileKeyValueSource) List(ctx context.Context, prefix string) (map[string]string, error) {
+	files, err := ioutil.ReadDir(f.Dir)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || strings.HasPrefix(name, ".") || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		value, _, err := f.Get(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, nil
+}
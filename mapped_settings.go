@@ -45,9 +45,10 @@ func (m *mappedSettings) OrderSettings(commands []*Command) {
 		foundCurrent := false
 		index := 0
 		expandedName := command.GetExpandedName()
-		for _, setSettings := range orderedSettings {
+		for i, setSettings := range orderedSettings {
 			if setSettings.CommandPath == expandedName {
 				foundCurrent = true
+				index = i
 			}
 		}
 		if !foundCurrent {
@@ -57,7 +58,11 @@ func (m *mappedSettings) OrderSettings(commands []*Command) {
 				Settings:    make([][]*activeSetting, 0),
 			})
 		}
-		orderedSettings[index].Settings = append(orderedSettings[index].Settings, settings)
+		if keyed, ok := variable.(keyedVariable); ok {
+			orderedSettings[index].Settings = append(orderedSettings[index].Settings, splitSettingsByKey(keyed, settings)...)
+		} else {
+			orderedSettings[index].Settings = append(orderedSettings[index].Settings, settings)
+		}
 	})
 	m.OrderedSettings = orderedSettings
 }
@@ -125,7 +130,7 @@ func (m *mappedSettings) checkDuplicatePointers(commands []*Command) {
 }
 
 // Helper to print duplciates in table format to Stdout.
-func (m *mappedSettings) PrintDuplicates(commands []*Command) {
+func (m *mappedSettings) PrintDuplicates(sourceName func(ParsingType) string) {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{"Command", "Variable", "Source", "Value", "Type", "Status"})
 	for _, commandSettings := range m.OrderedSettings {
@@ -135,6 +140,10 @@ func (m *mappedSettings) PrintDuplicates(commands []*Command) {
 				var status string
 				if setting.DuplicateDestination {
 					status = "x Overwritten Destination"
+				} else if setting.Rejected {
+					status = "x Rejected"
+				} else if setting.Merged {
+					status = "✔ Appended"
 				} else if i != length-1 {
 					status = "x Ignored"
 				} else {
@@ -142,14 +151,19 @@ func (m *mappedSettings) PrintDuplicates(commands []*Command) {
 				}
 				row := []string{
 					setting.CommandPath,
-					setting.VariableName,
-					ParsingTypeStringMap[setting.Source],
-					fmt.Sprintf("%v", setting.Value),
-					reflect.TypeOf(setting.Value).String(),
+					setting.GetDisplayName(),
+					sourceName(setting.Source),
+					setting.GetDisplayValue(),
+					displayedType(setting.Value),
 					status,
 				}
 				if setting.Source == EnvironmentVariables {
-					row[1] += " (" + convertNameToOS(setting.VariableName) + ")"
+					row[1] += " (" + setting.SettingName + ")"
+				} else if setting.SettingName != "" {
+					row[2] += " (" + setting.SettingName + ")"
+				}
+				if setting.Alias != "" {
+					row[2] += " (alias " + setting.Alias + ")"
 				}
 				table.Append(row)
 			}
@@ -159,27 +173,22 @@ func (m *mappedSettings) PrintDuplicates(commands []*Command) {
 }
 
 // Use a custom formatted string to print duplicates on Stdout.
-func (m *mappedSettings) PrintDuplicatesStdout(noColor bool) {
+func (m *mappedSettings) PrintDuplicatesStdout(noColor bool, sourceName func(ParsingType) string) {
 	t := template.New("duplicates")
 	funcMap := getBaseFuncMap(noColor)
 
 	funcMap["sourceString"] = func(setting *activeSetting) string {
-		if setting.Source == EnvironmentVariables {
-			return fmt.Sprintf("%s (%s)", ParsingTypeStringMap[setting.Source], convertNameToOS(setting.VariableName))
-		} else if setting.Source == TomlConfig || setting.Source == JsonConfig {
-			return fmt.Sprintf("%s (%s)", ParsingTypeStringMap[setting.Source], setting.SettingName)
-		} else {
-			return ParsingTypeStringMap[setting.Source]
+		source := sourceName(setting.Source)
+		if setting.SettingName != "" {
+			source += fmt.Sprintf(" (%s)", setting.SettingName)
 		}
+		if setting.Alias != "" {
+			source += fmt.Sprintf(" (alias %s)", setting.Alias)
+		}
+		return source
 	}
 
-	funcMap["stringify"] = func(x interface{}) string {
-		return fmt.Sprintf("%v", x)
-	}
-
-	funcMap["getType"] = func(x interface{}) string {
-		return reflect.TypeOf(x).String()
-	}
+	funcMap["getType"] = displayedType
 
 	t.Funcs(funcMap)
 	t.Parse(`{{ range $i, $allSettings := . -}}
@@ -189,13 +198,19 @@ func (m *mappedSettings) PrintDuplicatesStdout(noColor bool) {
 -------------
 {{ range $j, $var := $settings -}}{{ $length := len $settings -}}
     {{ if $var.DuplicateDestination -}}
-		{{ red $var.VariableName }} = {{ red (stringify $var.Value) }} ({{ getType $var.Value }})
+		{{ red $var.GetDisplayName }} = {{ red $var.GetDisplayValue }} ({{ getType $var.Value }})
 	{{ red "ignored" }} {{ sourceString $var -}} {{ red " overwritten pointer." -}}
+	{{ else if $var.Rejected -}}
+		{{ red $var.GetDisplayName }} = {{ red $var.GetDisplayValue }} ({{ getType $var.Value }})
+	{{ red "rejected from" }} {{ sourceString $var -}}
+	{{ else if $var.Merged -}}
+		{{ green $var.GetDisplayName }} = {{ green $var.GetDisplayValue }} ({{ getType $var.Value }})
+	{{ green "appended from" }} {{ sourceString $var -}}
 	{{ else if eq $length (plus1 $j) -}}
-		{{ green $var.VariableName }} = {{ green (stringify $var.Value) }} ({{ getType $var.Value }})
+		{{ green $var.GetDisplayName }} = {{ green $var.GetDisplayValue }} ({{ getType $var.Value }})
 	{{ green "set from" }} {{ sourceString $var -}}
 	{{ else -}}
-		{{ red $var.VariableName }} = {{ red (stringify $var.Value) }}
+		{{ red $var.GetDisplayName }} = {{ red $var.GetDisplayValue }}
 	{{ red "ignored" }} {{ sourceString $var -}}
 	{{ end }}
 {{ end -}}
@@ -203,3 +218,27 @@ func (m *mappedSettings) PrintDuplicatesStdout(noColor bool) {
 {{ end }}`)
 	t.Execute(os.Stdout, m.OrderedSettings)
 }
+
+// Generic variables set pointers to the parsed value, which are shown as the value they point to.
+func displayedValue(value interface{}) interface{} {
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() != reflect.Ptr || reflected.IsNil() {
+		return value
+	}
+	if _, ok := value.(fmt.Stringer); ok {
+		return value
+	}
+	return reflected.Elem().Interface()
+}
+
+// The type shown for a value, the type pointers point to for generic variables.
+func displayedType(value interface{}) string {
+	valueType := reflect.TypeOf(value)
+	if valueType == nil {
+		return ""
+	}
+	if valueType.Kind() == reflect.Ptr {
+		return valueType.Elem().String()
+	}
+	return valueType.String()
+}
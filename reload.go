@@ -0,0 +1,385 @@
+package unpuzzled
+
+import (
+	"context"
+	"html/template"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+// A variable changed by a reload, passed to App.OnChange. Values of sensitive variables are masked,
+// read the destination for the new value.
+type Change struct {
+	Command  string
+	Variable string
+	OldValue interface{}
+	NewValue interface{}
+	// The source of the new value, DefaultValue if the variable is no longer set.
+	Source      ParsingType
+	SettingName string
+	// The name of the source, set for sources registered with the App.
+	SourceName string
+}
+
+// The values and config files before a reload, restored if the reload fails.
+type reloadState struct {
+	settingsMap *mappedSettings
+	values      map[Variable]interface{}
+	configs     map[*ConfigVariable]*configState
+}
+
+// The files loaded by a ConfigVariable.
+type configState struct {
+	configs    []*loadedConfig
+	paths      []string
+	pathSource ParsingType
+}
+
+// Re-read the config files and every source in the ParsingOrder, and apply the new values. Flags are kept from the run.
+// If any value fails to parse, or the required variables, constraint groups or validation fail, the previous values are
+// restored and the error is returned. App.OnChange is called with the variables that changed.
+// Variables that are no longer set by any source are reset to the value their destination had before the run.
+//
+// Only Reloadable variables are changed, and each variable's OnChange can reject its change. Rejected changes are printed
+// with the override output format and returned as ReloadErrors, the other changes are still applied.
+// The checks run on the values without the rejected changes, if they fail every value is restored.
+// Variable OnChange funcs are called while holding the app's lock, so they can't call App.View or App.Snapshot.
+//
+// Destinations are updated while holding the app's lock, use App.View or App.Snapshot to read them from other goroutines.
+func (a *App) Reload() error {
+	a.mutex.Lock()
+	if a.settingsMap == nil {
+		a.mutex.Unlock()
+		return ErrNotParsed
+	}
+	previous := a.saveReloadState()
+	parseErrors, err := a.parseSources()
+	if err == nil && len(parseErrors) > 0 {
+		a.nameParseErrors(parseErrors)
+		a.maskParseErrors(parseErrors)
+		a.parseErrors = parseErrors
+		a.PrintParseErrors()
+		err = parseErrors
+	}
+	var changes []*Change
+	var reloadErrors ReloadErrors
+	rejectedSettings := newMappedSettings()
+	if err == nil {
+		// changes to variables that aren't Reloadable are reverted before the checks.
+		_, reloadErrors = a.applyChanges(previous, rejectedSettings, checkReloadable)
+		err = a.checkVariables()
+	}
+	if err == nil {
+		var rejected ReloadErrors
+		changes, rejected = a.applyChanges(previous, rejectedSettings, callOnChange)
+		reloadErrors = append(reloadErrors, rejected...)
+		if len(rejected) > 0 {
+			// check the values again without the rejected changes.
+			err = a.checkVariables()
+		}
+	}
+	if err != nil {
+		a.restoreReloadState(previous)
+		a.mutex.Unlock()
+		return err
+	}
+	if len(reloadErrors) > 0 {
+		a.printRejectedChanges(rejectedSettings)
+	}
+	a.mutex.Unlock()
+
+	if len(changes) > 0 && a.OnChange != nil {
+		a.OnChange(changes)
+	}
+	if len(reloadErrors) > 0 {
+		return reloadErrors
+	}
+	return nil
+}
+
+// Reload when a loaded config file changes, checked every interval, or when the process receives SIGHUP.
+// An interval of 0 only reloads on SIGHUP. Key-value sources are read with ctx. Blocks until ctx is done,
+// and returns ctx.Err(). Failed reloads are passed to App.OnReloadError, and the previous values are kept.
+func (a *App) Watch(ctx context.Context, interval time.Duration) error {
+	if interval < 0 {
+		return ErrInvalidInterval
+	}
+	a.mutex.Lock()
+	if a.settingsMap == nil {
+		a.mutex.Unlock()
+		return ErrNotParsed
+	}
+	previousCtx := a.ctx
+	a.ctx = ctx
+	a.mutex.Unlock()
+	defer func() {
+		a.mutex.Lock()
+		a.ctx = previousCtx
+		a.mutex.Unlock()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	// a nil channel never receives, so files are only checked with an interval.
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	reload := func() {
+		if err := a.Reload(); err != nil && a.OnReloadError != nil {
+			a.OnReloadError(err)
+		}
+	}
+	files := a.getWatchedFiles()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-signals:
+			reload()
+			files = a.getWatchedFiles()
+		case <-ticks:
+			if current := a.getWatchedFiles(); !reflect.DeepEqual(files, current) {
+				reload()
+				files = a.getWatchedFiles()
+			}
+		}
+	}
+}
+
+// Run fn while no reload can change the destinations, to read several variables consistently.
+func (a *App) View(fn func()) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	fn()
+}
+
+// A copy of the value of every active variable, keyed by the command path and name, ex. "main.sub1.port".
+func (a *App) Snapshot() map[string]interface{} {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	snapshot := make(map[string]interface{})
+	a.Command.loopActiveVariables(func(command *Command, variable Variable) {
+		if value, ok := getDestinationValue(variable); ok {
+			snapshot[command.GetExpandedName()+"."+variable.GetName()] = value
+		}
+	})
+	return snapshot
+}
+
+// The value a variable's Destination points to. Slices and maps are replaced when they're set, so the value can be shared.
+func getDestinationValue(variable Variable) (interface{}, bool) {
+	if _, ok := variable.(*ConfigVariable); ok {
+		return nil, false
+	}
+	destination := reflect.ValueOf(variable.GetDestination())
+	if destination.Kind() != reflect.Ptr || destination.IsNil() {
+		return nil, false
+	}
+	return destination.Elem().Interface(), true
+}
+
+func setDestinationValue(variable Variable, value interface{}) {
+	destination := reflect.ValueOf(variable.GetDestination()).Elem()
+	if value == nil {
+		destination.Set(reflect.Zero(destination.Type()))
+	} else {
+		destination.Set(reflect.ValueOf(value))
+	}
+}
+
+// Save the value of every destination before it's first set, used to reset variables that are no longer set.
+func (a *App) saveInitialValues() {
+	if a.initialValues == nil {
+		a.initialValues = make(map[Variable]interface{})
+	}
+	a.Command.loopActiveVariables(func(command *Command, variable Variable) {
+		if _, saved := a.initialValues[variable]; saved {
+			return
+		}
+		if value, ok := getDestinationValue(variable); ok {
+			a.initialValues[variable] = value
+		}
+	})
+}
+
+// Reset the destinations of variables without any setting to their value before the first run,
+// so values removed from a source are cleared on reload.
+func (a *App) resetUnsetVariables() {
+	a.Command.loopActiveVariables(func(command *Command, variable Variable) {
+		if len(a.settingsMap.MainMap[command.GetExpandedName()][variable.GetName()]) > 0 {
+			return
+		}
+		if value, ok := a.initialValues[variable]; ok {
+			setDestinationValue(variable, value)
+		}
+	})
+}
+
+func (a *App) saveReloadState() *reloadState {
+	state := &reloadState{
+		settingsMap: a.settingsMap,
+		values:      make(map[Variable]interface{}),
+		configs:     make(map[*ConfigVariable]*configState),
+	}
+	a.Command.loopActiveVariables(func(command *Command, variable Variable) {
+		if value, ok := getDestinationValue(variable); ok {
+			state.values[variable] = value
+		}
+	})
+	a.Command.loopActiveCommands(func(command *Command) {
+		for _, configVar := range command.configVars {
+			state.configs[configVar] = &configState{
+				configs:    configVar.configs,
+				paths:      configVar.paths,
+				pathSource: configVar.pathSource,
+			}
+		}
+	})
+	return state
+}
+
+func (a *App) restoreReloadState(state *reloadState) {
+	a.settingsMap = state.settingsMap
+	for variable, value := range state.values {
+		setDestinationValue(variable, value)
+	}
+	for configVar, saved := range state.configs {
+		configVar.configs = saved.configs
+		configVar.paths = saved.paths
+		configVar.pathSource = saved.pathSource
+	}
+}
+
+// Compare the destinations with the values before the reload. Changes that accept returns an error for are reverted,
+// added to the rejected settings and returned as ReloadErrors.
+func (a *App) applyChanges(state *reloadState, rejectedSettings *mappedSettings, accept func(variable Variable, oldValue interface{}, value interface{}) error) ([]*Change, ReloadErrors) {
+	var changes []*Change
+	var reloadErrors ReloadErrors
+	a.Command.loopActiveVariables(func(command *Command, variable Variable) {
+		value, ok := getDestinationValue(variable)
+		oldValue := state.values[variable]
+		if !ok || reflect.DeepEqual(oldValue, value) {
+			return
+		}
+		path := command.GetExpandedName()
+		newSettings := a.settingsMap.MainMap[path][variable.GetName()]
+		oldSettings := state.settingsMap.MainMap[path][variable.GetName()]
+		change := &Change{
+			Command:  path,
+			Variable: variable.GetName(),
+			OldValue: oldValue,
+			NewValue: value,
+			Source:   DefaultValue,
+		}
+		sensitive := isSensitive(variable)
+		if len(newSettings) > 0 {
+			last := newSettings[len(newSettings)-1]
+			change.Source = last.Source
+			change.SettingName = last.SettingName
+		}
+		change.SourceName = a.sourceNames[change.Source]
+		for _, setting := range append(append([]*activeSetting{}, oldSettings...), newSettings...) {
+			sensitive = sensitive || setting.Sensitive
+		}
+		if sensitive {
+			change.OldValue, change.NewValue = maskedValue, maskedValue
+		}
+
+		err := accept(variable, oldValue, value)
+		if err == nil {
+			changes = append(changes, change)
+			return
+		}
+		setDestinationValue(variable, oldValue)
+		a.settingsMap.MainMap[path][variable.GetName()] = oldSettings
+		reloadErrors = append(reloadErrors, &ReloadError{Change: change, Err: err})
+		rejectedSettings.addParsedArray(getRejectedSettings(variable, oldSettings, newSettings))
+	})
+	return changes, reloadErrors
+}
+
+func checkReloadable(variable Variable, oldValue interface{}, value interface{}) error {
+	if !variable.options().Reloadable {
+		return ErrNotReloadable
+	}
+	return nil
+}
+
+func callOnChange(variable Variable, oldValue interface{}, value interface{}) error {
+	if onChange := variable.options().OnChange; onChange != nil {
+		return onChange(oldValue, value)
+	}
+	return nil
+}
+
+// The settings shown for a rejected change, the rejected values followed by the values that are kept.
+// Keyed variables show every setting, so each key is compared.
+func getRejectedSettings(variable Variable, oldSettings []*activeSetting, newSettings []*activeSetting) []*activeSetting {
+	if _, ok := variable.(keyedVariable); !ok && len(newSettings) > 0 {
+		newSettings = newSettings[len(newSettings)-1:]
+		if len(oldSettings) > 0 {
+			oldSettings = oldSettings[len(oldSettings)-1:]
+		}
+	}
+	settings := make([]*activeSetting, 0, len(oldSettings)+len(newSettings))
+	for _, setting := range newSettings {
+		rejected := *setting
+		rejected.Rejected = true
+		settings = append(settings, &rejected)
+	}
+	return append(settings, oldSettings...)
+}
+
+// Print the rejected changes with the override output format.
+func (a *App) printRejectedChanges(rejectedSettings *mappedSettings) {
+	if a.Silent {
+		return
+	}
+	t := template.New("rejected-changes")
+	t.Funcs(getBaseFuncMap(a.RemoveColor))
+	t.Parse(`---------------------------
+{{ bold (red "Rejected Changes:") }}
+---------------------------
+`)
+	t.Execute(os.Stdout, nil)
+	rejectedSettings.OrderSettings(a.activeCommands)
+	if a.OverridesOutputInTable {
+		rejectedSettings.PrintDuplicates(a.sourceName)
+	} else {
+		rejectedSettings.PrintDuplicatesStdout(a.RemoveColor, a.sourceName)
+	}
+}
+
+type watchedFile struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// The state of every config file and directory loaded by the active commands.
+func (a *App) getWatchedFiles() map[string]watchedFile {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	files := make(map[string]watchedFile)
+	a.Command.loopActiveCommands(func(command *Command) {
+		for _, configVar := range command.configVars {
+			for _, path := range append(append([]string{}, configVar.paths...), configVar.LoadedPaths()...) {
+				info, err := os.Stat(path)
+				if err != nil {
+					files[path] = watchedFile{}
+					continue
+				}
+				files[path] = watchedFile{exists: true, size: info.Size(), modTime: info.ModTime()}
+			}
+		}
+	})
+	return files
+}
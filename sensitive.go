@@ -0,0 +1,79 @@
+package unpuzzled
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Shown instead of the values of sensitive variables.
+const maskedValue = "******"
+
+// A StringVariable that's always Sensitive, for passwords and API tokens.
+type SecretStringVariable struct {
+	*StringVariable
+}
+
+func (s *SecretStringVariable) options() VariableOptions {
+	options := s.StringVariable.options()
+	options.Sensitive = true
+	return options
+}
+
+func isSensitive(variable Variable) bool {
+	return variable.options().Sensitive
+}
+
+// Value used in the override output, masked for sensitive variables.
+func (a *activeSetting) GetDisplayValue() string {
+	if a.Sensitive {
+		return maskedValue
+	}
+	return fmt.Sprintf("%v", displayedValue(a.Value))
+}
+
+// Mask the value and destination of sensitive settings.
+func (a *activeSetting) MarshalJSON() ([]byte, error) {
+	type setting activeSetting
+	out := setting(*a)
+	if a.Sensitive {
+		out.Value = maskedValue
+		out.Destination = maskedValue
+	}
+	return json.Marshal(&out)
+}
+
+// Mark the settings of sensitive variables, so the values are masked in every output.
+func (m *mappedSettings) markSensitive(commands []*Command) {
+	m.loopCommands(commands, func(command *Command, variable Variable, settings []*activeSetting) {
+		if !isSensitive(variable) {
+			return
+		}
+		for _, setting := range settings {
+			setting.Sensitive = true
+		}
+	})
+}
+
+// Mask the raw values of sensitive variables in parse errors, including the copies in the error messages.
+func (a *App) maskParseErrors(parseErrors ParseErrors) {
+	sensitive := make(map[string]bool)
+	a.Command.loopActiveVariables(func(command *Command, variable Variable) {
+		if isSensitive(variable) {
+			sensitive[command.GetExpandedName()+"."+variable.GetName()] = true
+		}
+	})
+	for _, parseError := range parseErrors {
+		if !sensitive[parseError.Command+"."+parseError.Variable] {
+			continue
+		}
+		if parseError.RawValue != "" && parseError.Err != nil {
+			// flag errors quote the value, which escapes some characters.
+			message := strings.Replace(parseError.Err.Error(), strconv.Quote(parseError.RawValue), strconv.Quote(maskedValue), -1)
+			parseError.Err = errors.New(strings.Replace(message, parseError.RawValue, maskedValue, -1))
+		}
+		parseError.RawValue = maskedValue
+	}
+}
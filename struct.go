@@ -0,0 +1,310 @@
+package unpuzzled
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Options of an `unpuzzled:"..."` struct tag.
+type structTag struct {
+	name       string
+	desc       string
+	env        string
+	short      string
+	aliases    []string
+	def        string
+	hasDefault bool
+	required   bool
+	sensitive  bool
+	reloadable bool
+	command    bool
+	skip       bool
+}
+
+// Tag options with a value, a part of the tag that doesn't start with one of these continues the previous value,
+// so values can contain commas, ex. `unpuzzled:"default=a,b,desc=Tags, separated by commas"`
+var structTagValueOptions = map[string]bool{
+	"name":    true,
+	"desc":    true,
+	"env":     true,
+	"short":   true,
+	"aliases": true,
+	"default": true,
+}
+
+var structTagFlagOptions = map[string]bool{
+	"required":   true,
+	"sensitive":  true,
+	"reloadable": true,
+	"command":    true,
+}
+
+// Build the variables for every exported field of a struct, ex. unpuzzled.FromStruct(&config)
+// Fields are configured with the `unpuzzled` tag, with comma separated options:
+//
+//	name=port         the variable name, defaults to the field name in kebab case (ListenPort is "listen-port")
+//	default=8080      the default value, parsed like an environment variable
+//	desc=...          the description
+//	env=PORT          the EnvName
+//	short=p           the short flag
+//	aliases=a|b       aliases, separated by "|"
+//	required, sensitive, reloadable
+//
+// Fields tagged `unpuzzled:"-"` are skipped. Nested structs use dotted names, ex. "database.host" for the Host field of a
+// Database struct, which reads the [main.database] table from config files. Embedded structs are flattened.
+// Fields of any type implementing flag.Value or encoding.TextUnmarshaler use a GenericVariable.
+func FromStruct(v interface{}) ([]Variable, error) {
+	value, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	variables, _, err := structVariables(value, "", false)
+	return variables, err
+}
+
+// Build a command from a struct, the same way as FromStruct. Nested structs tagged `unpuzzled:"command"` are subcommands,
+// named with the name option and described with the desc option.
+func CommandFromStruct(name string, v interface{}) (*Command, error) {
+	value, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return structCommand(name, "", value)
+}
+
+func structValue(v interface{}) (reflect.Value, error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("Expected a pointer to a struct, got %T.", v)
+	}
+	return value.Elem(), nil
+}
+
+func structCommand(name string, usage string, value reflect.Value) (*Command, error) {
+	variables, subcommands, err := structVariables(value, "", true)
+	if err != nil {
+		return nil, err
+	}
+	return &Command{
+		Name:        name,
+		Usage:       usage,
+		Variables:   variables,
+		Subcommands: subcommands,
+	}, nil
+}
+
+// Build the variables of a struct, names are added to the prefix. Subcommands are only allowed if commands is true.
+func structVariables(value reflect.Value, prefix string, commands bool) ([]Variable, []*Command, error) {
+	var variables []Variable
+	var subcommands []*Command
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		// unexported fields can't be set.
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag, err := parseStructTag(field.Tag.Get("unpuzzled"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("Field %s: %v", field.Name, err)
+		}
+		if tag.skip {
+			continue
+		}
+		fieldValue := value.Field(i)
+		name := tag.name
+		if name == "" {
+			name = kebabCase(field.Name)
+		}
+
+		if tag.command {
+			if !commands {
+				return nil, nil, fmt.Errorf("Field %s: commands are only supported by CommandFromStruct.", field.Name)
+			}
+			if fieldValue.Kind() != reflect.Struct {
+				return nil, nil, fmt.Errorf("Field %s: commands must be structs, got %s.", field.Name, field.Type)
+			}
+			subcommand, err := structCommand(name, tag.desc, fieldValue)
+			if err != nil {
+				return nil, nil, err
+			}
+			subcommands = append(subcommands, subcommand)
+			continue
+		}
+
+		if fieldValue.Kind() == reflect.Struct && !isGenericType(fieldValue) {
+			nestedPrefix := prefix + name + "."
+			if field.Anonymous && tag.name == "" {
+				nestedPrefix = prefix
+			}
+			nestedVariables, nestedCommands, err := structVariables(fieldValue, nestedPrefix, commands)
+			if err != nil {
+				return nil, nil, err
+			}
+			variables = append(variables, nestedVariables...)
+			subcommands = append(subcommands, nestedCommands...)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		variable, err := newStructVariable(fieldValue.Addr().Interface(), prefix+name, tag)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Field %s: %v", field.Name, err)
+		}
+		variables = append(variables, variable)
+	}
+	return variables, subcommands, nil
+}
+
+func parseStructTag(tag string) (*structTag, error) {
+	out := &structTag{}
+	if tag == "-" {
+		out.skip = true
+		return out, nil
+	}
+	if tag == "" {
+		return out, nil
+	}
+	var lastOption string
+	values := make(map[string]string)
+	for _, part := range strings.Split(tag, ",") {
+		key := strings.TrimSpace(strings.SplitN(part, "=", 2)[0])
+		switch {
+		case structTagValueOptions[key] && strings.Contains(part, "="):
+			values[key] = strings.SplitN(part, "=", 2)[1]
+			lastOption = key
+		case structTagFlagOptions[key] && !strings.Contains(part, "="):
+			values[key] = ""
+			lastOption = ""
+		case lastOption != "":
+			values[lastOption] += "," + part
+		default:
+			return nil, fmt.Errorf("Unknown tag option %q.", part)
+		}
+	}
+	out.name = values["name"]
+	out.desc = values["desc"]
+	out.env = values["env"]
+	out.short = values["short"]
+	if aliases := values["aliases"]; aliases != "" {
+		out.aliases = strings.Split(aliases, "|")
+	}
+	out.def, out.hasDefault = values["default"]
+	_, out.required = values["required"]
+	_, out.sensitive = values["sensitive"]
+	_, out.reloadable = values["reloadable"]
+	_, out.command = values["command"]
+	return out, nil
+}
+
+var (
+	flagValueType       = reflect.TypeOf((*flag.Value)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Types set with a GenericVariable instead of nested variables, ex. time.Time
+func isGenericType(value reflect.Value) bool {
+	pointerType := reflect.PtrTo(value.Type())
+	return pointerType.Implements(flagValueType) || pointerType.Implements(textUnmarshalerType)
+}
+
+func newStructVariable(destination interface{}, name string, tag *structTag) (Variable, error) {
+	options := VariableOptions{
+		Aliases:    tag.aliases,
+		Short:      tag.short,
+		EnvName:    tag.env,
+		Sensitive:  tag.sensitive,
+		Reloadable: tag.reloadable,
+	}
+	// sets the Default of the variable to a value parsed with setEnv.
+	var setDefault func(interface{})
+	var variable Variable
+	switch d := destination.(type) {
+	case *bool:
+		v := &BoolVariable{Name: name, Description: tag.desc, Required: tag.required, VariableOptions: options, Destination: d}
+		setDefault = func(value interface{}) { v.Default = value.(bool) }
+		variable = v
+	case *int:
+		v := &IntVariable{Name: name, Description: tag.desc, Required: tag.required, VariableOptions: options, Destination: d}
+		setDefault = func(value interface{}) { v.Default, v.HasDefault = value.(int), true }
+		variable = v
+	case *time.Duration:
+		v := &DurationVariable{Name: name, Description: tag.desc, Required: tag.required, VariableOptions: options, Destination: d}
+		setDefault = func(value interface{}) { v.Default, v.HasDefault = value.(time.Duration), true }
+		variable = v
+	case *int64:
+		v := &Int64Variable{Name: name, Description: tag.desc, Required: tag.required, VariableOptions: options, Destination: d}
+		setDefault = func(value interface{}) { v.Default, v.HasDefault = value.(int64), true }
+		variable = v
+	case *float64:
+		v := &Float64Variable{Name: name, Description: tag.desc, Required: tag.required, VariableOptions: options, Destination: d}
+		setDefault = func(value interface{}) { v.Default, v.HasDefault = value.(float64), true }
+		variable = v
+	case *string:
+		v := &StringVariable{Name: name, Description: tag.desc, Required: tag.required, VariableOptions: options, Destination: d}
+		setDefault = func(value interface{}) { v.Default, v.HasDefault = value.(string), true }
+		variable = v
+	case *[]string:
+		v := &StringSliceVariable{Name: name, Description: tag.desc, Required: tag.required, VariableOptions: options, Destination: d}
+		setDefault = func(value interface{}) { v.Default = value.([]string) }
+		variable = v
+	case *[]int:
+		v := &IntSliceVariable{Name: name, Description: tag.desc, Required: tag.required, VariableOptions: options, Destination: d}
+		setDefault = func(value interface{}) { v.Default = value.([]int) }
+		variable = v
+	case *[]time.Duration:
+		v := &DurationSliceVariable{Name: name, Description: tag.desc, Required: tag.required, VariableOptions: options, Destination: d}
+		setDefault = func(value interface{}) { v.Default = value.([]time.Duration) }
+		variable = v
+	case *map[string]string:
+		v := &StringMapVariable{Name: name, Description: tag.desc, Required: tag.required, VariableOptions: options, Destination: d}
+		setDefault = func(value interface{}) { v.Default = value.(map[string]string) }
+		variable = v
+	case *map[string]int:
+		v := &IntMapVariable{Name: name, Description: tag.desc, Required: tag.required, VariableOptions: options, Destination: d}
+		setDefault = func(value interface{}) { v.Default = value.(map[string]int) }
+		variable = v
+	case flag.Value, encoding.TextUnmarshaler:
+		v := &GenericVariable{Name: name, Description: tag.desc, Required: tag.required, VariableOptions: options, Destination: d}
+		if tag.hasDefault {
+			// generic defaults are parsed when they're applied.
+			v.Default, v.HasDefault = tag.def, true
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("Unsupported type %s.", reflect.TypeOf(destination).Elem())
+	}
+
+	if tag.hasDefault {
+		parsed, err := variable.setEnv(tag.def, "")
+		if err != nil {
+			return nil, fmt.Errorf("Invalid default %q: %v", tag.def, err)
+		}
+		setDefault(parsed)
+	}
+	return variable, nil
+}
+
+// Convert a field name to the variable name, ex. ListenPort to "listen-port", HTTPPort to "http-port".
+func kebabCase(name string) string {
+	runes := []rune(name)
+	var out []rune
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			previousLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if previousLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				out = append(out, '-')
+			}
+		}
+		out = append(out, unicode.ToLower(r))
+	}
+	return string(out)
+}
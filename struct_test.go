@@ -0,0 +1,113 @@
+package unpuzzled
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testStructCommon struct {
+	Debug bool
+}
+
+type testStructConfig struct {
+	testStructCommon
+	Port     int               `unpuzzled:"default=8080,short=p,env=PORT_NUMBER,desc=Listen port, for HTTP"`
+	HTTPHost string            `unpuzzled:"required"`
+	Tags     []string          `unpuzzled:"default=a,b"`
+	Labels   map[string]string `unpuzzled:"default=k1=v1,k2=v2"`
+	Timeout  time.Duration     `unpuzzled:"default=5s,reloadable"`
+	Token    string            `unpuzzled:"sensitive,aliases=api-key|key"`
+	Bind     net.IP            `unpuzzled:"default=127.0.0.1"`
+	Ignored  string            `unpuzzled:"-"`
+	Database struct {
+		Host string `unpuzzled:"default=localhost"`
+		Port int
+	}
+	Serve struct {
+		Workers int `unpuzzled:"default=1"`
+	} `unpuzzled:"command,desc=Run the server"`
+	unexported int
+}
+
+func TestFromStruct(t *testing.T) {
+	config := &testStructConfig{}
+	command, err := CommandFromStruct("main", config)
+	if !assert.NoError(t, err) {
+		return
+	}
+	names := make([]string, len(command.Variables))
+	for i, variable := range command.Variables {
+		names[i] = variable.GetName()
+	}
+	assert.Equal(t, []string{"debug", "port", "http-host", "tags", "labels", "timeout", "token", "bind", "database.host", "database.port"}, names)
+	assert.Equal(t, &IntVariable{
+		Name:        "port",
+		Description: "Listen port, for HTTP",
+		Default:     8080,
+		HasDefault:  true,
+		Destination: &config.Port,
+		VariableOptions: VariableOptions{
+			Short:   "p",
+			EnvName: "PORT_NUMBER",
+		},
+	}, command.Variables[1])
+	assert.True(t, command.Variables[2].IsRequired())
+	assert.Equal(t, []string{"api-key", "key"}, command.Variables[6].options().Aliases)
+	assert.True(t, command.Variables[6].options().Sensitive)
+	assert.True(t, command.Variables[5].options().Reloadable)
+	if assert.Len(t, command.Subcommands, 1) {
+		assert.Equal(t, "serve", command.Subcommands[0].Name)
+		assert.Equal(t, "Run the server", command.Subcommands[0].Usage)
+	}
+
+	os.Setenv("DATABASE_HOST", "db.internal")
+	defer os.Unsetenv("DATABASE_HOST")
+	app := NewApp()
+	app.Silent = true
+	app.Command = command
+	err = app.RunE([]string{"path_to_exec", "--http-host=example.com", "-p=9000", "--key=secret", "--debug", "--database.port=5432", "serve", "--workers=4"})
+	assert.NoError(t, err)
+	assert.Equal(t, 9000, config.Port)
+	assert.Equal(t, "example.com", config.HTTPHost)
+	assert.Equal(t, []string{"a", "b"}, config.Tags)
+	assert.Equal(t, map[string]string{"k1": "v1", "k2": "v2"}, config.Labels)
+	assert.Equal(t, 5*time.Second, config.Timeout)
+	assert.Equal(t, "secret", config.Token)
+	assert.Equal(t, "127.0.0.1", config.Bind.String())
+	assert.True(t, config.Debug, "Embedded struct fields should be flattened.")
+	assert.Equal(t, "db.internal", config.Database.Host, "Nested structs should use dotted names.")
+	assert.Equal(t, 5432, config.Database.Port)
+	assert.Equal(t, 4, config.Serve.Workers)
+
+	variables, err := FromStruct(&struct {
+		Database struct {
+			Host string
+		}
+	}{})
+	if assert.NoError(t, err) && assert.Len(t, variables, 1) {
+		assert.Equal(t, "database.host", variables[0].GetName())
+	}
+
+	_, err = FromStruct(config)
+	assert.EqualError(t, err, "Field Serve: commands are only supported by CommandFromStruct.")
+	_, err = FromStruct(&struct{ Value *int }{})
+	assert.EqualError(t, err, "Field Value: Unsupported type *int.")
+	_, err = FromStruct(&struct {
+		Value int `unpuzzled:"default=abc"`
+	}{})
+	assert.Error(t, err, "Invalid defaults should be an error.")
+	_, err = FromStruct(&struct {
+		Value int `unpuzzled:"requried"`
+	}{})
+	assert.EqualError(t, err, `Field Value: Unknown tag option "requried".`)
+	_, err = FromStruct(testStructConfig{})
+	assert.EqualError(t, err, "Expected a pointer to a struct, got unpuzzled.testStructConfig.")
+
+	assert.Equal(t, "listen-port", kebabCase("ListenPort"))
+	assert.Equal(t, "http-port", kebabCase("HTTPPort"))
+	assert.Equal(t, "int64-val", kebabCase("Int64Val"))
+}
@@ -1,7 +1,9 @@
 package unpuzzled
 
 import (
+	"flag"
 	"html/template"
+	"os"
 
 	"github.com/fatih/color"
 )
@@ -14,6 +16,22 @@ func reverseStringSlice(a []string) {
 	}
 }
 
+// Check if a flag was passed in the arguments, so zero values can be told apart from unset flags.
+func isFlagSet(set *flag.FlagSet, names ...string) bool {
+	if set == nil {
+		return false
+	}
+	found := false
+	set.Visit(func(f *flag.Flag) {
+		for _, name := range names {
+			if f.Name == name {
+				found = true
+			}
+		}
+	})
+	return found
+}
+
 func getBaseFuncMap(noColor bool) template.FuncMap {
 	funcMap := template.FuncMap{
 		"blue":  color.BlueString,
@@ -39,3 +57,23 @@ func getBaseFuncMap(noColor bool) template.FuncMap {
 func identityString(s string) string {
 	return s
 }
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func isDirectory(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// Get the first environment variable that's set, returning its name.
+func lookupEnv(names []string) (string, string, bool) {
+	for _, name := range names {
+		if value, found := os.LookupEnv(name); found {
+			return value, name, true
+		}
+	}
+	return "", "", false
+}
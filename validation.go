@@ -0,0 +1,335 @@
+package unpuzzled
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A declarative check on a variable's value, set in a variable's Constraints.
+// Constraints are checked after every source is applied, for variables that are set.
+type Constraint interface {
+	// Return an error if the value is invalid.
+	Check(value interface{}) error
+	// Short description used in the help text, ex. "min 1"
+	Hint() string
+}
+
+// Numbers must be at least n. Durations are compared in nanoseconds, ex. Min(float64(time.Second)).
+// Each element is checked for slice and map variables. Only int, int64, float64 and time.Duration values can be checked.
+func Min(n float64) Constraint {
+	return &minConstraint{n}
+}
+
+// Numbers must be at most n. Durations are compared in nanoseconds, ex. Max(float64(time.Minute)).
+// Each element is checked for slice and map variables. Only int, int64, float64 and time.Duration values can be checked.
+func Max(n float64) Constraint {
+	return &maxConstraint{n}
+}
+
+// Values must be one of the given values, non string values are compared with fmt.Sprint.
+// Each element is checked for slice and map variables.
+func OneOf(values ...string) Constraint {
+	return &oneOfConstraint{values}
+}
+
+// Values must match the regular expression, non string values are matched with fmt.Sprint.
+// Each element is checked for slice and map variables. Panics if the expression is invalid.
+func Pattern(expr string) Constraint {
+	return &patternConstraint{regexp.MustCompile(expr)}
+}
+
+// Strings, slices and maps must not be empty.
+func NonEmpty() Constraint {
+	return &nonEmptyConstraint{}
+}
+
+// Optionally implemented by constraints that can only check some types, checked before the sources are parsed.
+type typedConstraint interface {
+	appliesTo(reflect.Type) bool
+}
+
+// Optionally implemented by constraints with a help text hint that depends on the variable's type.
+type typedHint interface {
+	hintFor(reflect.Type) string
+}
+
+type minConstraint struct {
+	min float64
+}
+
+func (m *minConstraint) Check(value interface{}) error {
+	return checkElements(value, func(element interface{}) error {
+		if number, ok := toFloat64(element); ok && number < m.min {
+			return fmt.Errorf("Must be at least %v.", formatNumber(element, m.min))
+		}
+		return nil
+	})
+}
+
+func (m *minConstraint) Hint() string {
+	return "min " + formatNumber(nil, m.min)
+}
+
+func (m *minConstraint) hintFor(t reflect.Type) string {
+	return "min " + formatNumber(reflect.Zero(elementType(t)).Interface(), m.min)
+}
+
+func (m *minConstraint) appliesTo(t reflect.Type) bool {
+	return isNumberType(elementType(t))
+}
+
+type maxConstraint struct {
+	max float64
+}
+
+func (m *maxConstraint) Check(value interface{}) error {
+	return checkElements(value, func(element interface{}) error {
+		if number, ok := toFloat64(element); ok && number > m.max {
+			return fmt.Errorf("Must be at most %v.", formatNumber(element, m.max))
+		}
+		return nil
+	})
+}
+
+func (m *maxConstraint) Hint() string {
+	return "max " + formatNumber(nil, m.max)
+}
+
+func (m *maxConstraint) hintFor(t reflect.Type) string {
+	return "max " + formatNumber(reflect.Zero(elementType(t)).Interface(), m.max)
+}
+
+func (m *maxConstraint) appliesTo(t reflect.Type) bool {
+	return isNumberType(elementType(t))
+}
+
+type oneOfConstraint struct {
+	values []string
+}
+
+func (o *oneOfConstraint) Check(value interface{}) error {
+	return checkElements(value, func(element interface{}) error {
+		stringVal := fmt.Sprint(element)
+		for _, allowed := range o.values {
+			if stringVal == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("Must be one of: %s.", strings.Join(o.values, ", "))
+	})
+}
+
+func (o *oneOfConstraint) Hint() string {
+	return "one of: " + strings.Join(o.values, ", ")
+}
+
+type patternConstraint struct {
+	pattern *regexp.Regexp
+}
+
+func (p *patternConstraint) Check(value interface{}) error {
+	return checkElements(value, func(element interface{}) error {
+		if !p.pattern.MatchString(fmt.Sprint(element)) {
+			return fmt.Errorf("Must match %s.", p.pattern.String())
+		}
+		return nil
+	})
+}
+
+func (p *patternConstraint) Hint() string {
+	return "matches " + p.pattern.String()
+}
+
+type nonEmptyConstraint struct{}
+
+func (n *nonEmptyConstraint) Check(value interface{}) error {
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		if reflected.Len() == 0 {
+			return fmt.Errorf("Must not be empty.")
+		}
+	}
+	return nil
+}
+
+func (n *nonEmptyConstraint) Hint() string {
+	return "non-empty"
+}
+
+func (n *nonEmptyConstraint) appliesTo(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// Run the check on each element of slices and map values, or on the value itself.
+func checkElements(value interface{}, check func(interface{}) error) error {
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Slice:
+		for i := 0; i < reflected.Len(); i++ {
+			if err := check(reflected.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		for _, key := range reflected.MapKeys() {
+			if err := check(reflected.MapIndex(key).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(value)
+}
+
+// The type of the elements of slices and maps, or the type itself.
+func elementType(t reflect.Type) reflect.Type {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map:
+		return t.Elem()
+	}
+	return t
+}
+
+// Types that can be compared by Min and Max.
+func isNumberType(t reflect.Type) bool {
+	_, ok := toFloat64(reflect.Zero(t).Interface())
+	return ok
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case float64:
+		return number, true
+	case time.Duration:
+		return float64(number), true
+	}
+	return 0, false
+}
+
+// Show limits for durations as durations, and other limits without an exponent.
+func formatNumber(value interface{}, limit float64) string {
+	if _, ok := value.(time.Duration); ok {
+		return time.Duration(limit).String()
+	}
+	return strconv.FormatFloat(limit, 'f', -1, 64)
+}
+
+// The constraint hints shown in the help text, ex. "min 1, max 65535"
+func getConstraintHints(variable Variable) string {
+	constraints := variable.options().Constraints
+	destination := destinationType(variable)
+	hints := make([]string, len(constraints))
+	for i, constraint := range constraints {
+		if typed, ok := constraint.(typedHint); ok && destination != nil {
+			hints[i] = typed.hintFor(destination)
+		} else {
+			hints[i] = constraint.Hint()
+		}
+	}
+	return strings.Join(hints, ", ")
+}
+
+// The type a variable's Destination points to, nil if it isn't a pointer.
+func destinationType(variable Variable) reflect.Type {
+	destination := reflect.TypeOf(variable.GetDestination())
+	if destination == nil || destination.Kind() != reflect.Ptr {
+		return nil
+	}
+	return destination.Elem()
+}
+
+// Ensure every constraint can check the type of its variable's destination.
+func (c *Command) checkConstraints() error {
+	var err error
+	c.loopActiveVariables(func(command *Command, variable Variable) {
+		destination := destinationType(variable)
+		if err != nil || destination == nil {
+			return
+		}
+		for _, constraint := range variable.options().Constraints {
+			if typed, ok := constraint.(typedConstraint); ok && !typed.appliesTo(destination) {
+				err = &ConstraintTypeError{
+					Command:    command.GetExpandedName(),
+					Variable:   variable.GetName(),
+					Constraint: constraint.Hint(),
+					Type:       destination.String(),
+				}
+				return
+			}
+		}
+	})
+	return err
+}
+
+// Check the Constraints and Validate func of every active variable that's set.
+func (a *App) validateVariables() ValidationErrors {
+	var validationErrors ValidationErrors
+	a.Command.loopActiveVariables(func(command *Command, variable Variable) {
+		options := variable.options()
+		if options.Validate == nil && len(options.Constraints) == 0 {
+			return
+		}
+		path := command.GetExpandedName()
+		settings := a.settingsMap.MainMap[path][variable.GetName()]
+		if len(settings) == 0 {
+			return
+		}
+		destination := reflect.ValueOf(variable.GetDestination())
+		if destination.Kind() != reflect.Ptr || destination.IsNil() {
+			return
+		}
+		value := destination.Elem().Interface()
+		displayValue := value
+		if isSensitive(variable) || settings[len(settings)-1].Sensitive {
+			displayValue = maskedValue
+		}
+
+		addError := func(err error) {
+			validationErrors = append(validationErrors, &ValidationError{
+				Command:    path,
+				Variable:   variable.GetName(),
+				Value:      displayValue,
+				Source:     settings[len(settings)-1].Source,
+				SourceName: a.sourceNames[settings[len(settings)-1].Source],
+				Err:        err,
+			})
+		}
+		for _, constraint := range options.Constraints {
+			if err := constraint.Check(value); err != nil {
+				addError(err)
+			}
+		}
+		if options.Validate != nil {
+			if err := options.Validate(value); err != nil {
+				addError(err)
+			}
+		}
+	})
+	return validationErrors
+}
+
+// Print every value that failed validation, grouped by command.
+func (a *App) PrintValidationErrors() {
+	if a.Silent {
+		return
+	}
+	if a.validationErrors == nil {
+		panic("There are no validation errors.")
+	}
+	a.printCommandErrors("Invalid Variables:", `{{ green "--"}}{{ green $err.Variable }} = {{ noEscape (printf "%v" $err.Value) }} ({{ sourceString $err.Source }}) : {{ noEscape (printf "%v" $err.Err) }}
+`, a.validationErrors)
+}
@@ -2,6 +2,7 @@ package unpuzzled
 
 import (
 	"flag"
+	"fmt"
 	"regexp"
 	"strings"
 )
@@ -18,14 +19,70 @@ type Variable interface {
 	// Get if it's a required variable or not
 	IsRequired() bool
 
+	options() VariableOptions
 	setDefaults()
 	setFlag(*flag.FlagSet)
-	// os value, env name, return value and a bool representing if it's a valid setting
-	setEnv(string, string) (interface{}, bool)
-	apply(interface{})
+	// os value, env name, return the parsed value or an error if it's not a valid setting
+	setEnv(string, string) (interface{}, error)
+	apply(interface{}) error
 	getFlagValue(*flag.FlagSet) (interface{}, bool)
 }
 
+// Options shared by every variable type, embedded in each variable, ex.
+//
+//	&unpuzzled.IntVariable{
+//		Name:            "port",
+//		Destination:     &port,
+//		VariableOptions: unpuzzled.VariableOptions{Short: "p", Reloadable: true},
+//	}
+type VariableOptions struct {
+	// Other names for the flag, config files and environment variables, ex. old names after a rename.
+	Aliases []string
+	// Single letter flag, ex. "p" for -p.
+	Short string
+	// Warning shown when the variable is set from any source, ex. "Use --listen-port instead."
+	Deprecated string
+	// Warnings shown when an alias is used, keyed by the alias.
+	DeprecatedAliases map[string]string
+	// Name of a variable in the same command that values are forwarded to.
+	ReplacedBy string
+	// Used instead of the generated environment variable name, ex. "PORT". The App.EnvPrefix is not added.
+	EnvName string
+	// Other environment variable names, checked in order if the main name isn't set.
+	EnvAliases []string
+	// Called with the final value after every source is applied, if the variable is set.
+	Validate func(value interface{}) error
+	// Checked with the final value if the variable is set, ex. unpuzzled.Min(1), unpuzzled.OneOf("debug", "info")
+	Constraints []Constraint
+	// Masks the value in the override output, help text, errors and JSON, ex. for passwords and API tokens.
+	Sensitive bool
+	// Read the value from a file if it exists, ex. "/run/secrets/db_password". <ENV NAME>_FILE environment variables are used first.
+	FromFile string
+	// Allow App.Reload to change the value, changes to other variables are rejected until a restart.
+	Reloadable bool
+	// Called by App.Reload before a change is applied, returning an error rejects the change.
+	OnChange func(old, new interface{}) error
+}
+
+func (o *VariableOptions) options() VariableOptions {
+	return *o
+}
+
+// The flag names for a variable, starting with the Name.
+func flagNames(variable Variable) []string {
+	options := variable.options()
+	names := append([]string{variable.GetName()}, options.Aliases...)
+	if options.Short != "" {
+		names = append(names, options.Short)
+	}
+	return names
+}
+
+// Returned from apply when a source gives a value that can't be converted to the destination type.
+func unsupportedTypeError(val interface{}) error {
+	return fmt.Errorf("Unsupported value type %T.", val)
+}
+
 var osToEnvReplaceRegexp = regexp.MustCompile(`[\.\-]`)
 
 func convertNameToOS(name string) string {
@@ -3,15 +3,16 @@ package unpuzzled
 import (
 	"flag"
 	"strconv"
-
-	log "github.com/sirupsen/logrus"
 )
 
 type BoolVariable struct {
 	Name        string
 	Description string
 	Required    bool
-	Default     bool
+	VariableOptions
+	Default bool
+	// Use the Default even if it's false, ex. to override a destination that's already true.
+	HasDefault  bool
 	Destination *bool
 
 	flagDestination *bool
@@ -34,21 +35,26 @@ func (b *BoolVariable) GetDestination() interface{} {
 }
 
 func (b *BoolVariable) GetDefault() (interface{}, bool) {
-	if b.Default {
+	if b.Default || b.HasDefault {
 		return b.Default, true
 	} else {
 		return b.Default, false
 	}
 }
 
-func (b *BoolVariable) apply(val interface{}) {
-	if boolVal, ok := val.(bool); ok {
-		*b.Destination = boolVal
+func (b *BoolVariable) apply(val interface{}) error {
+	boolVal, ok := val.(bool)
+	if !ok {
+		return unsupportedTypeError(val)
 	}
+	*b.Destination = boolVal
+	return nil
 }
 
 func (b *BoolVariable) setDefaults() {
-	*b.Destination = b.Default
+	if b.Default || b.HasDefault {
+		*b.Destination = b.Default
+	}
 }
 
 func (b *BoolVariable) setFlag(flagset *flag.FlagSet) {
@@ -58,18 +64,16 @@ func (b *BoolVariable) setFlag(flagset *flag.FlagSet) {
 }
 
 func (b *BoolVariable) getFlagValue(set *flag.FlagSet) (interface{}, bool) {
+	if !isFlagSet(set, flagNames(b)...) {
+		return nil, false
+	}
 	return *b.flagDestination, true
 }
 
-func (b *BoolVariable) setEnv(value string, envName string) (interface{}, bool) {
+func (b *BoolVariable) setEnv(value string, envName string) (interface{}, error) {
 	boolValue, err := strconv.ParseBool(value)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"envName":  envName,
-			"envValue": value,
-			"err":      err,
-			"name":     b.Name,
-		}).Fatal("Failed to parse bool value from environment.")
+		return nil, err
 	}
-	return boolValue, true
+	return boolValue, nil
 }
@@ -3,93 +3,295 @@ package unpuzzled
 import (
 	"errors"
 	"flag"
-	"io/ioutil"
-
-	"github.com/Jeffail/gabs"
-	"github.com/pelletier/go-toml"
-	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
+// The config files are set from, in order: the CLI flag, the environment variable, the Default, and the first
+// SearchPaths entry with a file. A missing file from the flag or environment is an error, a missing Default is skipped.
+//
+// Multiple files can be set with a repeated flag, a list separated by the os path list separator (ex. "base.toml:prod.toml"),
+// or glob patterns. Files are layered in order, values in later files override the same values in earlier files.
+//
+// With the ConfigDirectory type, the paths are directories, ex. "/etc/app/conf.d". Every file in the directory with a
+// known extension is loaded in lexical order, using the loader for the extension.
 type ConfigVariable struct {
 	*StringVariable
-	Type   ParsingType
-	config configGetter
+	// JsonConfig, TomlConfig, YamlConfig, ConfigDirectory, or a type registered with app.RegisterConfigLoader.
+	Type ParsingType
+	// Locations checked in order if the path isn't set, ex. "./app.toml", "$XDG_CONFIG_HOME/app/", "/etc/app/"
+	// Environment variables are expanded, and directories are joined with FileName.
+	SearchPaths []string
+	// File name used for directories in SearchPaths, ex. "app.toml"
+	FileName string
+	// Used with ConfigDirectory, the files to load from the directory, ex. "*.toml". Defaults to every file with a known extension.
+	Pattern string
+
+	configs    []*loadedConfig
+	paths      []string
+	pathSource ParsingType
+	pathsFlag  *sliceFlag
 }
 
-type configGetter interface {
-	GetByVariable(string) (interface{}, error)
+// A config file loaded by a ConfigVariable.
+type loadedConfig struct {
+	path   string
+	source ConfigSource
 }
 
 var (
 	ErrFailedToLoadToml  = errors.New("Failed to load toml.")
 	ErrFailedToLoadJson  = errors.New("Failed to load json.")
 	ErrConfigValueNotSet = errors.New("Config variable is not set.")
+	ErrConfigTypeUnknown = errors.New("Unimplemented config type.")
+	ErrConfigApply       = errors.New("Config Variable does not apply values.")
+	ErrNoConfigFiles     = errors.New("No files match the pattern.")
+	ErrNotDirectory      = errors.New("Config directory is not a directory.")
 )
 
-func (c *ConfigVariable) apply(interface{}) {
-	log.Fatal("Config Variable does not apply values.")
+var configPathSeparator = string(os.PathListSeparator)
+
+func (c *ConfigVariable) apply(interface{}) error {
+	return ErrConfigApply
 }
 
+// Load the config files set in the flagset, using the built in loaders.
 func (c *ConfigVariable) ParseConfig(set *flag.FlagSet) error {
-	// ignore error, library should handle this.
-	stringPointer, _ := c.StringVariable.getFlagValue(set)
-	if stringPointer == nil {
-		return ErrConfigValueNotSet
+	return c.parseConfig(set, getPrefixedEnvNames("", c), defaultLoaders{})
+}
+
+func (c *ConfigVariable) parseConfig(set *flag.FlagSet, envNames []string, loaders configLoaderLookup) error {
+	paths, source, err := c.resolvePaths(set, envNames)
+	if err == ErrConfigValueNotSet {
+		// the files from an earlier load were removed.
+		c.configs, c.paths = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	var configs []*loadedConfig
+	if c.Type == ConfigDirectory {
+		configs, err = c.loadDirectories(paths, loaders)
+	} else {
+		configs, err = c.loadFiles(paths, loaders.getConfigLoader(c.Type))
 	}
-	value := stringPointer.(string)
-	data, err := ioutil.ReadFile(value)
 	if err != nil {
 		return err
 	}
+	c.configs = configs
+	c.paths = paths
+	c.pathSource = source
+	return nil
+}
+
+func (c *ConfigVariable) loadFiles(paths []string, loader ConfigLoader) ([]*loadedConfig, error) {
+	if loader == nil {
+		return nil, c.configError(strings.Join(paths, configPathSeparator), ErrConfigTypeUnknown)
+	}
+	configs := make([]*loadedConfig, 0, len(paths))
+	for _, path := range paths {
+		config, err := loadConfigFile(path, loader)
+		if err != nil {
+			return nil, c.configError(path, err)
+		}
+		configs = append(configs, &loadedConfig{
+			path:   path,
+			source: config,
+		})
+	}
+	return configs, nil
+}
 
-	switch c.Type {
-	case TomlConfig:
-		tree, err := toml.Load(string(data))
+// Load the files in each directory in lexical order. Files without a loader for their extension are skipped,
+// unless they match a Pattern.
+func (c *ConfigVariable) loadDirectories(directories []string, loaders configLoaderLookup) ([]*loadedConfig, error) {
+	pattern := c.Pattern
+	if pattern == "" {
+		pattern = "*"
+	}
+	var configs []*loadedConfig
+	for _, directory := range directories {
+		info, err := os.Stat(directory)
 		if err != nil {
-			log.WithFields(log.Fields{"err": err}).Fatal(ErrFailedToLoadToml)
-			return ErrFailedToLoadToml
+			return nil, c.configError(directory, err)
 		}
-		config := &tomlConfig{
-			tree: tree,
+		if !info.IsDir() {
+			return nil, c.configError(directory, ErrNotDirectory)
 		}
-		c.config = config
-	case JsonConfig:
-		container, err := gabs.ParseJSON(data)
+		matches, err := filepath.Glob(filepath.Join(directory, pattern))
 		if err != nil {
-			log.WithFields(log.Fields{"err": err}).Fatal(ErrFailedToLoadJson)
-			return ErrFailedToLoadJson
+			return nil, c.configError(directory, err)
 		}
-		config := &jsonConfig{
-			container: container,
+		for _, path := range matches {
+			if strings.HasPrefix(filepath.Base(path), ".") || !fileExists(path) {
+				continue
+			}
+			loader := loaders.getExtensionLoader(filepath.Ext(path))
+			if loader == nil {
+				if c.Pattern == "" {
+					continue
+				}
+				return nil, c.configError(path, ErrConfigTypeUnknown)
+			}
+			config, err := loadConfigFile(path, loader)
+			if err != nil {
+				return nil, c.configError(path, err)
+			}
+			configs = append(configs, &loadedConfig{
+				path:   path,
+				source: config,
+			})
 		}
-		c.config = config
+	}
+	return configs, nil
+}
 
-	default:
-		log.Fatal("Unimplemented config type")
+func loadConfigFile(path string, loader ConfigLoader) (ConfigSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return loader.Load(file)
+}
 
+func (c *ConfigVariable) configError(path string, err error) *ConfigError {
+	return &ConfigError{
+		Type:     c.Type,
+		Variable: c.Name,
+		Path:     path,
+		Err:      err,
 	}
-	return nil
 }
 
-func (c *ConfigVariable) getConfigValue(path string) (interface{}, error) {
-	if c.config != nil {
-		return c.config.GetByVariable(path)
+// The paths of the loaded config files, in the order they're applied. For ConfigDirectory, the files loaded from the directories.
+func (c *ConfigVariable) LoadedPaths() []string {
+	paths := make([]string, len(c.configs))
+	for i, config := range c.configs {
+		paths[i] = config.path
 	}
-	return nil, nil
+	return paths
 }
 
-type tomlConfig struct {
-	tree *toml.Tree
+// Find the config paths by precedence, returning where they were set from.
+// Returns ErrConfigValueNotSet if no paths are set.
+func (c *ConfigVariable) resolvePaths(set *flag.FlagSet, envNames []string) ([]string, ParsingType, error) {
+	// ignore error, library should handle this.
+	if flagValue, _ := c.getFlagValue(set); flagValue != nil {
+		paths, err := c.expandPaths(flagValue.(string))
+		return paths, CliFlags, err
+	}
+	if envValue, _, _ := lookupEnv(envNames); envValue != "" {
+		paths, err := c.expandPaths(envValue)
+		return paths, EnvironmentVariables, err
+	}
+	if c.Default != "" {
+		if paths := c.existingPaths(splitSliceValue(c.Default, configPathSeparator)); len(paths) > 0 {
+			return paths, DefaultValue, nil
+		}
+	}
+	for _, path := range c.getSearchPaths() {
+		if paths := c.existingPaths([]string{path}); len(paths) > 0 {
+			return paths, ConfigSearchPath, nil
+		}
+	}
+	return nil, 0, ErrConfigValueNotSet
 }
 
-func (t *tomlConfig) GetByVariable(path string) (interface{}, error) {
-	return t.tree.Get(path), nil
+// Split a list of paths set by the user, expanding glob patterns. Paths without a pattern are kept, so missing files are reported when they're opened.
+func (c *ConfigVariable) expandPaths(value string) ([]string, error) {
+	var paths []string
+	for _, pattern := range splitSliceValue(value, configPathSeparator) {
+		if !strings.ContainsAny(pattern, "*?[") {
+			paths = append(paths, pattern)
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, c.configError(pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, c.configError(pattern, ErrNoConfigFiles)
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		return nil, ErrConfigValueNotSet
+	}
+	return paths, nil
 }
 
-type jsonConfig struct {
-	container *gabs.Container
+// Get the files, or directories for ConfigDirectory, that exist for a list of paths or glob patterns.
+func (c *ConfigVariable) existingPaths(patterns []string) []string {
+	var paths []string
+	for _, pattern := range patterns {
+		// only fails on a malformed pattern, which can't match a file.
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			if (c.Type == ConfigDirectory && isDirectory(match)) || (c.Type != ConfigDirectory && fileExists(match)) {
+				paths = append(paths, match)
+			}
+		}
+	}
+	return paths
 }
 
-func (j *jsonConfig) GetByVariable(path string) (interface{}, error) {
-	return j.container.Path(path).Data(), nil
+// Expand the search paths into file paths. Paths that use an unset environment variable are skipped, except for
+// $XDG_CONFIG_HOME, which defaults to $HOME/.config.
+func (c *ConfigVariable) getSearchPaths() []string {
+	paths := make([]string, 0, len(c.SearchPaths))
+	for _, searchPath := range c.SearchPaths {
+		unset := false
+		path := os.Expand(searchPath, func(name string) string {
+			value := os.Getenv(name)
+			if value == "" && name == "XDG_CONFIG_HOME" && os.Getenv("HOME") != "" {
+				value = filepath.Join(os.Getenv("HOME"), ".config")
+			}
+			unset = unset || value == ""
+			return value
+		})
+		if unset {
+			continue
+		}
+		if strings.HasSuffix(searchPath, "/") || isDirectory(path) {
+			path = filepath.Join(path, c.FileName)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// Every use of the flag adds to the list of config files.
+func (c *ConfigVariable) setFlag(flagset *flag.FlagSet) {
+	c.pathsFlag = &sliceFlag{
+		separator: configPathSeparator,
+		parse: func(string) error {
+			return nil
+		},
+	}
+	flagset.Var(c.pathsFlag, c.Name, c.Description)
+}
+
+func (c *ConfigVariable) getFlagValue(set *flag.FlagSet) (interface{}, bool) {
+	if !isFlagSet(set, flagNames(c)...) {
+		return nil, false
+	} else {
+		return c.pathsFlag.String(), true
+	}
+}
+
+// Set the destination to the loaded paths, the Default is only used if the files exist.
+func (c *ConfigVariable) setDefaults() {
+	if c.Destination != nil && len(c.paths) > 0 {
+		*c.Destination = strings.Join(c.paths, configPathSeparator)
+	}
+}
+
+// The setting for config paths found from the Default or SearchPaths, used for required variables and the override output.
+func (c *ConfigVariable) getDefaultSetting() (interface{}, ParsingType, bool) {
+	if len(c.paths) > 0 && (c.pathSource == DefaultValue || c.pathSource == ConfigSearchPath) {
+		return strings.Join(c.paths, configPathSeparator), c.pathSource, true
+	}
+	return nil, 0, false
 }
@@ -3,17 +3,18 @@ package unpuzzled
 import (
 	"flag"
 	"time"
-
-	log "github.com/sirupsen/logrus"
 )
 
 var zeroDuration = time.Duration(0)
 
 type DurationVariable struct {
-	Name            string
-	Description     string
-	Default         time.Duration
-	Required        bool
+	Name        string
+	Description string
+	Default     time.Duration
+	// Use the Default even if it's 0s.
+	HasDefault bool
+	Required   bool
+	VariableOptions
 	Destination     *time.Duration
 	flagDestination *time.Duration
 }
@@ -35,29 +36,30 @@ func (d *DurationVariable) IsRequired() bool {
 }
 
 func (d *DurationVariable) GetDefault() (interface{}, bool) {
-	if d.Default == zeroDuration {
+	if d.Default == zeroDuration && !d.HasDefault {
 		return zeroDuration, false
 	} else {
 		return d.Default, true
 	}
 }
 
-func (d *DurationVariable) apply(val interface{}) {
+func (d *DurationVariable) apply(val interface{}) error {
 	if duration, ok := val.(time.Duration); ok {
 		*d.Destination = duration
 	} else if stringVal, ok := val.(string); ok {
 		duration, err := time.ParseDuration(stringVal)
 		if err != nil {
-			log.WithFields(log.Fields{
-				"err": err,
-			}).Fatal("Failed to parse time.Duration.")
+			return err
 		}
 		*d.Destination = duration
+	} else {
+		return unsupportedTypeError(val)
 	}
+	return nil
 }
 
 func (d *DurationVariable) setDefaults() {
-	if d.Default != zeroDuration {
+	if d.Default != zeroDuration || d.HasDefault {
 		*d.Destination = d.Default
 	}
 }
@@ -68,21 +70,17 @@ func (d *DurationVariable) setFlag(flagset *flag.FlagSet) {
 }
 
 func (d *DurationVariable) getFlagValue(set *flag.FlagSet) (interface{}, bool) {
-	if *d.flagDestination == zeroDuration {
+	if !isFlagSet(set, flagNames(d)...) {
 		return nil, false
 	} else {
 		return *d.flagDestination, true
 	}
 }
 
-func (d *DurationVariable) setEnv(value string, envName string) (interface{}, bool) {
+func (d *DurationVariable) setEnv(value string, envName string) (interface{}, error) {
 	duration, err := time.ParseDuration(value)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"err": err,
-			"environmentVariable": envName,
-		}).Fatal("Failed to parse time.Duration from Environment.")
-		return nil, false
+		return nil, err
 	}
-	return duration, true
+	return duration, nil
 }
@@ -0,0 +1,120 @@
+package unpuzzled
+
+import (
+	"flag"
+	"time"
+)
+
+type DurationSliceVariable struct {
+	Name        string
+	Description string
+	Default     []time.Duration
+	Required    bool
+	VariableOptions
+	Destination *[]time.Duration
+	// Used to split environment variables and flags, defaults to ","
+	Separator string
+	// If true, values from every source are appended in the parsing order, instead of the last source replacing the others.
+	AppendSources bool
+
+	flagDestination *sliceFlag
+}
+
+func (d *DurationSliceVariable) GetName() string {
+	return d.Name
+}
+
+func (d *DurationSliceVariable) GetDescription() string {
+	return d.Description
+}
+
+func (d *DurationSliceVariable) GetDestination() interface{} {
+	return d.Destination
+}
+
+func (d *DurationSliceVariable) IsRequired() bool {
+	return d.Required
+}
+
+func (d *DurationSliceVariable) GetDefault() (interface{}, bool) {
+	if d.Default == nil {
+		return nil, false
+	} else {
+		return append([]time.Duration{}, d.Default...), true
+	}
+}
+
+func (d *DurationSliceVariable) apply(val interface{}) error {
+	*d.Destination = []time.Duration{}
+	return d.merge(val)
+}
+
+func (d *DurationSliceVariable) merge(val interface{}) error {
+	elements, err := sliceElements(val, getSeparator(d.Separator))
+	if err != nil {
+		return err
+	}
+	for _, element := range elements {
+		switch value := element.(type) {
+		case time.Duration:
+			*d.Destination = append(*d.Destination, value)
+		case string:
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			*d.Destination = append(*d.Destination, duration)
+		default:
+			return unsupportedTypeError(element)
+		}
+	}
+	return nil
+}
+
+func (d *DurationSliceVariable) mergesSources() bool {
+	return d.AppendSources
+}
+
+func (d *DurationSliceVariable) setDefaults() {
+	if d.Default != nil {
+		*d.Destination = append([]time.Duration{}, d.Default...)
+	}
+}
+
+func (d *DurationSliceVariable) setFlag(flagset *flag.FlagSet) {
+	d.flagDestination = &sliceFlag{
+		separator: getSeparator(d.Separator),
+		parse: func(value string) error {
+			_, err := time.ParseDuration(value)
+			return err
+		},
+	}
+	flagset.Var(d.flagDestination, d.Name, d.Description)
+}
+
+func (d *DurationSliceVariable) getFlagValue(set *flag.FlagSet) (interface{}, bool) {
+	if !isFlagSet(set, flagNames(d)...) {
+		return nil, false
+	}
+	values, err := parseDurationElements(d.flagDestination.values)
+	if err != nil {
+		return nil, false
+	}
+	return values, true
+}
+
+func (d *DurationSliceVariable) setEnv(value string, envName string) (interface{}, error) {
+	return parseDurationElements(splitSliceValue(value, getSeparator(d.Separator)))
+}
+
+func parseDurationElements(values []string) ([]time.Duration, error) {
+	out := make([]time.Duration, len(values))
+	for i, value := range values {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = duration
+	}
+	return out, nil
+}
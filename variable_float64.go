@@ -3,15 +3,16 @@ package unpuzzled
 import (
 	"flag"
 	"strconv"
-
-	log "github.com/sirupsen/logrus"
 )
 
 type Float64Variable struct {
-	Name            string
-	Description     string
-	Default         float64
-	Required        bool
+	Name        string
+	Description string
+	Default     float64
+	// Use the Default even if it's 0.
+	HasDefault bool
+	Required   bool
+	VariableOptions
 	Destination     *float64
 	flagDestination *float64
 }
@@ -33,14 +34,14 @@ func (f *Float64Variable) IsRequired() bool {
 }
 
 func (f *Float64Variable) GetDefault() (interface{}, bool) {
-	if f.Default == float64(0) {
+	if f.Default == float64(0) && !f.HasDefault {
 		return nil, false
 	} else {
 		return f.Default, true
 	}
 }
 
-func (f *Float64Variable) apply(val interface{}) {
+func (f *Float64Variable) apply(val interface{}) error {
 	switch val.(type) {
 	case int:
 		value := val.(int)
@@ -51,11 +52,14 @@ func (f *Float64Variable) apply(val interface{}) {
 	case float64:
 		value := val.(float64)
 		*f.Destination = value
+	default:
+		return unsupportedTypeError(val)
 	}
+	return nil
 }
 
 func (f *Float64Variable) setDefaults() {
-	if f.Default != float64(0) {
+	if f.Default != float64(0) || f.HasDefault {
 		*f.Destination = f.Default
 	}
 }
@@ -67,22 +71,17 @@ func (f *Float64Variable) setFlag(flagset *flag.FlagSet) {
 }
 
 func (f *Float64Variable) getFlagValue(set *flag.FlagSet) (interface{}, bool) {
-	if *f.flagDestination == float64(0) {
+	if !isFlagSet(set, flagNames(f)...) {
 		return nil, false
 	} else {
 		return *f.flagDestination, true
 	}
 }
 
-func (f *Float64Variable) setEnv(value string, envName string) (interface{}, bool) {
+func (f *Float64Variable) setEnv(value string, envName string) (interface{}, error) {
 	floatVal, err := strconv.ParseFloat(value, 64)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"envName":  envName,
-			"err":      err,
-			"envValue": value,
-			"name":     f.Name,
-		}).Fatal("Failed to parse float from environment.")
+		return nil, err
 	}
-	return floatVal, true
+	return floatVal, nil
 }
@@ -0,0 +1,157 @@
+package unpuzzled
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"net/url"
+	"reflect"
+)
+
+// A variable for any type that implements flag.Value or encoding.TextUnmarshaler, ex. net.IP, log levels or enums.
+// Values from every source are parsed through the same interface, numbers and bools from config files are
+// converted to strings first.
+type GenericVariable struct {
+	Name        string
+	Description string
+	// Parsed into the Destination if no other source is set.
+	Default string
+	// Use the Default even if it's an empty string.
+	HasDefault bool
+	Required   bool
+	VariableOptions
+	// A pointer to a type implementing flag.Value or encoding.TextUnmarshaler.
+	Destination interface{}
+
+	flagDestination *genericFlag
+}
+
+// flag.Value used by GenericVariable, parses each value into a new instance of the destination type.
+type genericFlag struct {
+	variable *GenericVariable
+	raw      string
+	value    interface{}
+}
+
+func (g *genericFlag) String() string {
+	return g.raw
+}
+
+func (g *genericFlag) Set(value string) error {
+	parsed, err := g.variable.parse(value)
+	if err != nil {
+		return err
+	}
+	g.raw = value
+	g.value = parsed
+	return nil
+}
+
+func (g *GenericVariable) GetName() string {
+	return g.Name
+}
+
+func (g *GenericVariable) GetDescription() string {
+	return g.Description
+}
+
+func (g *GenericVariable) GetDestination() interface{} {
+	return g.Destination
+}
+
+func (g *GenericVariable) IsRequired() bool {
+	return g.Required
+}
+
+func (g *GenericVariable) GetDefault() (interface{}, bool) {
+	if g.Default == "" && !g.HasDefault {
+		return nil, false
+	} else {
+		return g.Default, true
+	}
+}
+
+func (g *GenericVariable) apply(val interface{}) error {
+	parsed := val
+	if reflect.TypeOf(val) != reflect.TypeOf(g.Destination) {
+		stringVal, err := genericString(val)
+		if err != nil {
+			return err
+		}
+		if parsed, err = g.parse(stringVal); err != nil {
+			return err
+		}
+	}
+	reflect.ValueOf(g.Destination).Elem().Set(reflect.ValueOf(parsed).Elem())
+	return nil
+}
+
+func (g *GenericVariable) setDefaults() {
+	if g.Default != "" || g.HasDefault {
+		// invalid defaults are reported when the default setting is applied.
+		g.apply(g.Default)
+	}
+}
+
+func (g *GenericVariable) setFlag(flagset *flag.FlagSet) {
+	g.flagDestination = &genericFlag{
+		variable: g,
+	}
+	flagset.Var(g.flagDestination, g.Name, g.Description)
+}
+
+func (g *GenericVariable) getFlagValue(set *flag.FlagSet) (interface{}, bool) {
+	if !isFlagSet(set, flagNames(g)...) {
+		return nil, false
+	} else {
+		return g.flagDestination.value, true
+	}
+}
+
+func (g *GenericVariable) setEnv(value string, envName string) (interface{}, error) {
+	return g.parse(value)
+}
+
+// Parse a string into a new instance of the destination type.
+func (g *GenericVariable) parse(value string) (interface{}, error) {
+	destinationType := reflect.TypeOf(g.Destination)
+	if destinationType == nil || destinationType.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("Destination must be a pointer, got %T.", g.Destination)
+	}
+	parsed := reflect.New(destinationType.Elem()).Interface()
+	switch destination := parsed.(type) {
+	case flag.Value:
+		return parsed, destination.Set(value)
+	case encoding.TextUnmarshaler:
+		return parsed, destination.UnmarshalText([]byte(value))
+	}
+	return nil, fmt.Errorf("%T does not implement flag.Value or encoding.TextUnmarshaler.", g.Destination)
+}
+
+// A url.URL for the Destination of a GenericVariable, since url.URL implements neither flag.Value nor
+// encoding.TextUnmarshaler. ex. var endpoint unpuzzled.URLValue, with Destination: &endpoint
+type URLValue struct {
+	url.URL
+}
+
+func (u *URLValue) String() string {
+	return u.URL.String()
+}
+
+func (u *URLValue) Set(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	u.URL = *parsed
+	return nil
+}
+
+// Convert a string, number or bool from a config file into a string.
+func genericString(val interface{}) (string, error) {
+	switch val.(type) {
+	case string, bool, int, int64, float64:
+		return fmt.Sprintf("%v", val), nil
+	}
+	return "", unsupportedTypeError(val)
+}
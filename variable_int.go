@@ -3,15 +3,16 @@ package unpuzzled
 import (
 	"flag"
 	"strconv"
-
-	log "github.com/sirupsen/logrus"
 )
 
 type IntVariable struct {
-	Name            string
-	Description     string
-	Default         int
-	Required        bool
+	Name        string
+	Description string
+	Default     int
+	// Use the Default even if it's 0.
+	HasDefault bool
+	Required   bool
+	VariableOptions
 	Destination     *int
 	flagDestination *int
 }
@@ -33,14 +34,14 @@ func (i *IntVariable) IsRequired() bool {
 }
 
 func (i *IntVariable) GetDefault() (interface{}, bool) {
-	if i.Default == 0 {
+	if i.Default == 0 && !i.HasDefault {
 		return nil, false
 	} else {
 		return i.Default, true
 	}
 }
 
-func (i *IntVariable) apply(val interface{}) {
+func (i *IntVariable) apply(val interface{}) error {
 	switch val.(type) {
 	case int:
 		value := val.(int)
@@ -51,11 +52,14 @@ func (i *IntVariable) apply(val interface{}) {
 	case float64:
 		value := val.(float64)
 		*i.Destination = int(value)
+	default:
+		return unsupportedTypeError(val)
 	}
+	return nil
 }
 
 func (i *IntVariable) setDefaults() {
-	if i.Default != 0 {
+	if i.Default != 0 || i.HasDefault {
 		*i.Destination = i.Default
 	}
 }
@@ -67,22 +71,17 @@ func (i *IntVariable) setFlag(flagset *flag.FlagSet) {
 }
 
 func (i *IntVariable) getFlagValue(set *flag.FlagSet) (interface{}, bool) {
-	if *i.flagDestination == 0 {
+	if !isFlagSet(set, flagNames(i)...) {
 		return nil, false
 	} else {
 		return *i.flagDestination, true
 	}
 }
 
-func (i *IntVariable) setEnv(value string, envName string) (interface{}, bool) {
+func (i *IntVariable) setEnv(value string, envName string) (interface{}, error) {
 	int64val, err := strconv.ParseInt(value, 0, 64)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"envName":  envName,
-			"err":      err,
-			"envValue": value,
-			"name":     i.Name,
-		}).Fatal("Failed to parse from environment.")
+		return nil, err
 	}
-	return int(int64val), true
+	return int(int64val), nil
 }
@@ -3,15 +3,16 @@ package unpuzzled
 import (
 	"flag"
 	"strconv"
-
-	log "github.com/sirupsen/logrus"
 )
 
 type Int64Variable struct {
-	Name            string
-	Description     string
-	Default         int64
-	Required        bool
+	Name        string
+	Description string
+	Default     int64
+	// Use the Default even if it's 0.
+	HasDefault bool
+	Required   bool
+	VariableOptions
 	Destination     *int64
 	flagDestination *int64
 }
@@ -33,14 +34,14 @@ func (i *Int64Variable) IsRequired() bool {
 }
 
 func (i *Int64Variable) GetDefault() (interface{}, bool) {
-	if i.Default == 0 {
+	if i.Default == 0 && !i.HasDefault {
 		return nil, false
 	} else {
 		return i.Default, true
 	}
 }
 
-func (i *Int64Variable) apply(val interface{}) {
+func (i *Int64Variable) apply(val interface{}) error {
 	switch val.(type) {
 	case int:
 		value := val.(int)
@@ -51,11 +52,14 @@ func (i *Int64Variable) apply(val interface{}) {
 	case float64:
 		value := val.(float64)
 		*i.Destination = int64(value)
+	default:
+		return unsupportedTypeError(val)
 	}
+	return nil
 }
 
 func (i *Int64Variable) setDefaults() {
-	if i.Default != int64(0) {
+	if i.Default != int64(0) || i.HasDefault {
 		*i.Destination = i.Default
 	}
 }
@@ -67,22 +71,17 @@ func (i *Int64Variable) setFlag(flagset *flag.FlagSet) {
 }
 
 func (i *Int64Variable) getFlagValue(set *flag.FlagSet) (interface{}, bool) {
-	if *i.flagDestination == int64(0) {
+	if !isFlagSet(set, flagNames(i)...) {
 		return nil, false
 	} else {
 		return *i.flagDestination, true
 	}
 }
 
-func (i *Int64Variable) setEnv(value string, envName string) (interface{}, bool) {
+func (i *Int64Variable) setEnv(value string, envName string) (interface{}, error) {
 	parsedValue, err := strconv.ParseInt(value, 0, 64)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"envName":  envName,
-			"err":      err,
-			"envValue": value,
-			"name":     i.Name,
-		}).Fatal("Failed to parse from environment.")
+		return nil, err
 	}
-	return parsedValue, true
+	return parsedValue, nil
 }
@@ -0,0 +1,147 @@
+package unpuzzled
+
+import (
+	"flag"
+)
+
+type IntMapVariable struct {
+	Name        string
+	Description string
+	Default     map[string]int
+	Required    bool
+	VariableOptions
+	Destination *map[string]int
+	// Used to split entries in environment variables and flags, defaults to ","
+	Separator string
+
+	flagDestination *sliceFlag
+}
+
+func (i *IntMapVariable) GetName() string {
+	return i.Name
+}
+
+func (i *IntMapVariable) GetDescription() string {
+	return i.Description
+}
+
+func (i *IntMapVariable) GetDestination() interface{} {
+	return i.Destination
+}
+
+func (i *IntMapVariable) IsRequired() bool {
+	return i.Required
+}
+
+func (i *IntMapVariable) GetDefault() (interface{}, bool) {
+	if i.Default == nil {
+		return nil, false
+	} else {
+		return copyIntMap(i.Default), true
+	}
+}
+
+func (i *IntMapVariable) apply(val interface{}) error {
+	*i.Destination = make(map[string]int)
+	return i.merge(val)
+}
+
+func (i *IntMapVariable) merge(val interface{}) error {
+	entries, err := i.getEntries(val)
+	if err != nil {
+		return err
+	}
+	for key, value := range entries {
+		(*i.Destination)[key] = value.(int)
+	}
+	return nil
+}
+
+func (i *IntMapVariable) mergesSources() bool {
+	return true
+}
+
+func (i *IntMapVariable) getEntries(val interface{}) (map[string]interface{}, error) {
+	entries, err := mapEntries(val, getSeparator(i.Separator))
+	if err != nil {
+		return nil, err
+	}
+	for key, element := range entries {
+		switch value := element.(type) {
+		case int:
+		case int64:
+			entries[key] = int(value)
+		case float64:
+			entries[key] = int(value)
+		case string:
+			intVal, err := parseIntElement(value)
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = intVal
+		default:
+			return nil, unsupportedTypeError(element)
+		}
+	}
+	return entries, nil
+}
+
+func (i *IntMapVariable) setDefaults() {
+	if i.Default != nil {
+		*i.Destination = copyIntMap(i.Default)
+	}
+}
+
+func (i *IntMapVariable) setFlag(flagset *flag.FlagSet) {
+	i.flagDestination = &sliceFlag{
+		separator: getSeparator(i.Separator),
+		parse: func(entry string) error {
+			_, value, err := parseMapEntry(entry)
+			if err != nil {
+				return err
+			}
+			_, err = parseIntElement(value)
+			return err
+		},
+	}
+	flagset.Var(i.flagDestination, i.Name, i.Description)
+}
+
+func (i *IntMapVariable) getFlagValue(set *flag.FlagSet) (interface{}, bool) {
+	if !isFlagSet(set, flagNames(i)...) {
+		return nil, false
+	}
+	entries := make(map[string]int)
+	for _, entry := range i.flagDestination.values {
+		key, value, err := parseMapEntry(entry)
+		if err != nil {
+			return nil, false
+		}
+		if entries[key], err = parseIntElement(value); err != nil {
+			return nil, false
+		}
+	}
+	return entries, true
+}
+
+func (i *IntMapVariable) setEnv(value string, envName string) (interface{}, error) {
+	entries, err := parseMapEntries(value, getSeparator(i.Separator))
+	if err != nil {
+		return nil, err
+	}
+	intEntries := make(map[string]int, len(entries))
+	for key, value := range entries {
+		if intEntries[key], err = parseIntElement(value); err != nil {
+			return nil, err
+		}
+	}
+	return intEntries, nil
+}
+
+func copyIntMap(m map[string]int) map[string]int {
+	out := make(map[string]int, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package unpuzzled
+
+import (
+	"flag"
+	"strconv"
+)
+
+type IntSliceVariable struct {
+	Name        string
+	Description string
+	Default     []int
+	Required    bool
+	VariableOptions
+	Destination *[]int
+	// Used to split environment variables and flags, defaults to ","
+	Separator string
+	// If true, values from every source are appended in the parsing order, instead of the last source replacing the others.
+	AppendSources bool
+
+	flagDestination *sliceFlag
+}
+
+func (i *IntSliceVariable) GetName() string {
+	return i.Name
+}
+
+func (i *IntSliceVariable) GetDescription() string {
+	return i.Description
+}
+
+func (i *IntSliceVariable) GetDestination() interface{} {
+	return i.Destination
+}
+
+func (i *IntSliceVariable) IsRequired() bool {
+	return i.Required
+}
+
+func (i *IntSliceVariable) GetDefault() (interface{}, bool) {
+	if i.Default == nil {
+		return nil, false
+	} else {
+		return append([]int{}, i.Default...), true
+	}
+}
+
+func (i *IntSliceVariable) apply(val interface{}) error {
+	*i.Destination = []int{}
+	return i.merge(val)
+}
+
+func (i *IntSliceVariable) merge(val interface{}) error {
+	elements, err := sliceElements(val, getSeparator(i.Separator))
+	if err != nil {
+		return err
+	}
+	for _, element := range elements {
+		switch value := element.(type) {
+		case int:
+			*i.Destination = append(*i.Destination, value)
+		case int64:
+			*i.Destination = append(*i.Destination, int(value))
+		case float64:
+			*i.Destination = append(*i.Destination, int(value))
+		case string:
+			intVal, err := parseIntElement(value)
+			if err != nil {
+				return err
+			}
+			*i.Destination = append(*i.Destination, intVal)
+		default:
+			return unsupportedTypeError(element)
+		}
+	}
+	return nil
+}
+
+func (i *IntSliceVariable) mergesSources() bool {
+	return i.AppendSources
+}
+
+func (i *IntSliceVariable) setDefaults() {
+	if i.Default != nil {
+		*i.Destination = append([]int{}, i.Default...)
+	}
+}
+
+func (i *IntSliceVariable) setFlag(flagset *flag.FlagSet) {
+	i.flagDestination = &sliceFlag{
+		separator: getSeparator(i.Separator),
+		parse: func(value string) error {
+			_, err := parseIntElement(value)
+			return err
+		},
+	}
+	flagset.Var(i.flagDestination, i.Name, i.Description)
+}
+
+func (i *IntSliceVariable) getFlagValue(set *flag.FlagSet) (interface{}, bool) {
+	if !isFlagSet(set, flagNames(i)...) {
+		return nil, false
+	}
+	values, err := parseIntElements(i.flagDestination.values)
+	if err != nil {
+		return nil, false
+	}
+	return values, true
+}
+
+func (i *IntSliceVariable) setEnv(value string, envName string) (interface{}, error) {
+	return parseIntElements(splitSliceValue(value, getSeparator(i.Separator)))
+}
+
+func parseIntElement(value string) (int, error) {
+	int64val, err := strconv.ParseInt(value, 0, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(int64val), nil
+}
+
+func parseIntElements(values []string) ([]int, error) {
+	out := make([]int, len(values))
+	for i, value := range values {
+		intVal, err := parseIntElement(value)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = intVal
+	}
+	return out, nil
+}
@@ -0,0 +1,97 @@
+package unpuzzled
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Implemented by map variables. Values from every source are merged by key, and reported per key.
+type keyedVariable interface {
+	mergingVariable
+	// Split a value from any source into its keys and values.
+	getEntries(interface{}) (map[string]interface{}, error)
+}
+
+// Parse a single "key=value" pair from a flag or environment variable.
+func parseMapEntry(entry string) (string, string, error) {
+	parts := strings.SplitN(entry, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("Expected key=value, got %q.", entry)
+	}
+	return parts[0], parts[1], nil
+}
+
+// Parse "k1=v1,k2=v2" from a flag or environment variable.
+func parseMapEntries(value string, separator string) (map[string]string, error) {
+	entries := make(map[string]string)
+	for _, entry := range splitSliceValue(value, separator) {
+		key, value, err := parseMapEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		entries[key] = value
+	}
+	return entries, nil
+}
+
+// Convert a value from any source into a map of entries.
+// Strings are parsed as "k1=v1,k2=v2", tables and objects from config files are used as is.
+func mapEntries(val interface{}, separator string) (map[string]interface{}, error) {
+	entries := make(map[string]interface{})
+	switch value := val.(type) {
+	case string:
+		parsed, err := parseMapEntries(value, separator)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range parsed {
+			entries[k] = v
+		}
+	case map[string]string:
+		for k, v := range value {
+			entries[k] = v
+		}
+	case map[string]int:
+		for k, v := range value {
+			entries[k] = v
+		}
+	case map[string]interface{}:
+		for k, v := range value {
+			entries[k] = v
+		}
+	default:
+		return nil, unsupportedTypeError(val)
+	}
+	return entries, nil
+}
+
+// Split the settings of a keyed variable into a list of settings for each key, sorted by key.
+// Used to report which source set each key.
+func splitSettingsByKey(variable keyedVariable, settings []*activeSetting) [][]*activeSetting {
+	keySettings := make(map[string][]*activeSetting)
+	for _, setting := range settings {
+		entries, err := variable.getEntries(setting.Value)
+		if err != nil {
+			continue
+		}
+		for key, value := range entries {
+			keySetting := *setting
+			keySetting.Key = key
+			keySetting.Value = value
+			keySetting.Merged = false
+			keySettings[key] = append(keySettings[key], &keySetting)
+		}
+	}
+	keys := make([]string, 0, len(keySettings))
+	for key := range keySettings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	split := make([][]*activeSetting, len(keys))
+	for i, key := range keys {
+		split[i] = keySettings[key]
+	}
+	return split
+}
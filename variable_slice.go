@@ -0,0 +1,80 @@
+package unpuzzled
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Default separator used to split environment variables and flags for slice variables.
+const DefaultSliceSeparator = ","
+
+// Implemented by variables that can combine the values from every source, instead of only using the last one set.
+type mergingVariable interface {
+	Variable
+	// If the values from every source should be combined.
+	mergesSources() bool
+	// apply a value on top of the currently applied value.
+	merge(interface{}) error
+}
+
+// flag.Value used by slice variables, every use of the flag adds to the list of values.
+// Values are split by the separator, and checked with parse before they're added.
+type sliceFlag struct {
+	values    []string
+	separator string
+	parse     func(string) error
+}
+
+func (s *sliceFlag) String() string {
+	return strings.Join(s.values, s.separator)
+}
+
+func (s *sliceFlag) Set(value string) error {
+	for _, element := range splitSliceValue(value, s.separator) {
+		if err := s.parse(element); err != nil {
+			return err
+		}
+		s.values = append(s.values, element)
+	}
+	return nil
+}
+
+func getSeparator(separator string) string {
+	if separator == "" {
+		return DefaultSliceSeparator
+	}
+	return separator
+}
+
+// Split a string value from a flag or environment variable, removing whitespace around each element.
+func splitSliceValue(value string, separator string) []string {
+	if strings.TrimSpace(value) == "" {
+		return []string{}
+	}
+	elements := strings.Split(value, separator)
+	for i, element := range elements {
+		elements[i] = strings.TrimSpace(element)
+	}
+	return elements
+}
+
+// Convert a value from any source into a list of elements.
+// Strings are split with the separator, arrays from config files are returned element by element.
+func sliceElements(val interface{}, separator string) ([]interface{}, error) {
+	if stringVal, ok := val.(string); ok {
+		var elements []interface{}
+		for _, element := range splitSliceValue(stringVal, separator) {
+			elements = append(elements, element)
+		}
+		return elements, nil
+	}
+	value := reflect.ValueOf(val)
+	if value.Kind() != reflect.Slice {
+		return nil, unsupportedTypeError(val)
+	}
+	elements := make([]interface{}, value.Len())
+	for i := range elements {
+		elements[i] = value.Index(i).Interface()
+	}
+	return elements, nil
+}
@@ -8,7 +8,10 @@ type StringVariable struct {
 	Name        string
 	Description string
 	Default     string
-	Required    bool
+	// Use the Default even if it's an empty string.
+	HasDefault bool
+	Required   bool
+	VariableOptions
 	Destination *string
 
 	flagDestination *string
@@ -31,21 +34,24 @@ func (s *StringVariable) IsRequired() bool {
 }
 
 func (s *StringVariable) GetDefault() (interface{}, bool) {
-	if s.Default == "" {
+	if s.Default == "" && !s.HasDefault {
 		return "", false
 	} else {
 		return s.Default, true
 	}
 }
 
-func (s *StringVariable) apply(val interface{}) {
-	if stringVal, ok := val.(string); ok {
-		*s.Destination = stringVal
+func (s *StringVariable) apply(val interface{}) error {
+	stringVal, ok := val.(string)
+	if !ok {
+		return unsupportedTypeError(val)
 	}
+	*s.Destination = stringVal
+	return nil
 }
 
 func (s *StringVariable) setDefaults() {
-	if s.Default != "" {
+	if s.Default != "" || s.HasDefault {
 		*s.Destination = s.Default
 	}
 }
@@ -57,13 +63,13 @@ func (s *StringVariable) setFlag(flagset *flag.FlagSet) {
 }
 
 func (s *StringVariable) getFlagValue(set *flag.FlagSet) (interface{}, bool) {
-	if *s.flagDestination == "" {
+	if !isFlagSet(set, flagNames(s)...) {
 		return nil, false
 	} else {
 		return *s.flagDestination, true
 	}
 }
 
-func (s *StringVariable) setEnv(value string, envName string) (interface{}, bool) {
-	return value, true
+func (s *StringVariable) setEnv(value string, envName string) (interface{}, error) {
+	return value, nil
 }
@@ -0,0 +1,119 @@
+package unpuzzled
+
+import (
+	"flag"
+)
+
+type StringMapVariable struct {
+	Name        string
+	Description string
+	Default     map[string]string
+	Required    bool
+	VariableOptions
+	Destination *map[string]string
+	// Used to split entries in environment variables and flags, defaults to ","
+	Separator string
+
+	flagDestination *sliceFlag
+}
+
+func (s *StringMapVariable) GetName() string {
+	return s.Name
+}
+
+func (s *StringMapVariable) GetDescription() string {
+	return s.Description
+}
+
+func (s *StringMapVariable) GetDestination() interface{} {
+	return s.Destination
+}
+
+func (s *StringMapVariable) IsRequired() bool {
+	return s.Required
+}
+
+func (s *StringMapVariable) GetDefault() (interface{}, bool) {
+	if s.Default == nil {
+		return nil, false
+	} else {
+		return copyStringMap(s.Default), true
+	}
+}
+
+func (s *StringMapVariable) apply(val interface{}) error {
+	*s.Destination = make(map[string]string)
+	return s.merge(val)
+}
+
+func (s *StringMapVariable) merge(val interface{}) error {
+	entries, err := s.getEntries(val)
+	if err != nil {
+		return err
+	}
+	for key, value := range entries {
+		(*s.Destination)[key] = value.(string)
+	}
+	return nil
+}
+
+func (s *StringMapVariable) mergesSources() bool {
+	return true
+}
+
+func (s *StringMapVariable) getEntries(val interface{}) (map[string]interface{}, error) {
+	entries, err := mapEntries(val, getSeparator(s.Separator))
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range entries {
+		if _, ok := value.(string); !ok {
+			return nil, unsupportedTypeError(value)
+		}
+	}
+	return entries, nil
+}
+
+func (s *StringMapVariable) setDefaults() {
+	if s.Default != nil {
+		*s.Destination = copyStringMap(s.Default)
+	}
+}
+
+func (s *StringMapVariable) setFlag(flagset *flag.FlagSet) {
+	s.flagDestination = &sliceFlag{
+		separator: getSeparator(s.Separator),
+		parse: func(value string) error {
+			_, _, err := parseMapEntry(value)
+			return err
+		},
+	}
+	flagset.Var(s.flagDestination, s.Name, s.Description)
+}
+
+func (s *StringMapVariable) getFlagValue(set *flag.FlagSet) (interface{}, bool) {
+	if !isFlagSet(set, flagNames(s)...) {
+		return nil, false
+	}
+	entries := make(map[string]string)
+	for _, entry := range s.flagDestination.values {
+		key, value, err := parseMapEntry(entry)
+		if err != nil {
+			return nil, false
+		}
+		entries[key] = value
+	}
+	return entries, true
+}
+
+func (s *StringMapVariable) setEnv(value string, envName string) (interface{}, error) {
+	return parseMapEntries(value, getSeparator(s.Separator))
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
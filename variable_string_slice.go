@@ -0,0 +1,96 @@
+package unpuzzled
+
+import (
+	"flag"
+)
+
+type StringSliceVariable struct {
+	Name        string
+	Description string
+	Default     []string
+	Required    bool
+	VariableOptions
+	Destination *[]string
+	// Used to split environment variables and flags, defaults to ","
+	Separator string
+	// If true, values from every source are appended in the parsing order, instead of the last source replacing the others.
+	AppendSources bool
+
+	flagDestination *sliceFlag
+}
+
+func (s *StringSliceVariable) GetName() string {
+	return s.Name
+}
+
+func (s *StringSliceVariable) GetDescription() string {
+	return s.Description
+}
+
+func (s *StringSliceVariable) GetDestination() interface{} {
+	return s.Destination
+}
+
+func (s *StringSliceVariable) IsRequired() bool {
+	return s.Required
+}
+
+func (s *StringSliceVariable) GetDefault() (interface{}, bool) {
+	if s.Default == nil {
+		return nil, false
+	} else {
+		return append([]string{}, s.Default...), true
+	}
+}
+
+func (s *StringSliceVariable) apply(val interface{}) error {
+	*s.Destination = []string{}
+	return s.merge(val)
+}
+
+func (s *StringSliceVariable) merge(val interface{}) error {
+	elements, err := sliceElements(val, getSeparator(s.Separator))
+	if err != nil {
+		return err
+	}
+	for _, element := range elements {
+		stringVal, ok := element.(string)
+		if !ok {
+			return unsupportedTypeError(element)
+		}
+		*s.Destination = append(*s.Destination, stringVal)
+	}
+	return nil
+}
+
+func (s *StringSliceVariable) mergesSources() bool {
+	return s.AppendSources
+}
+
+func (s *StringSliceVariable) setDefaults() {
+	if s.Default != nil {
+		*s.Destination = append([]string{}, s.Default...)
+	}
+}
+
+func (s *StringSliceVariable) setFlag(flagset *flag.FlagSet) {
+	s.flagDestination = &sliceFlag{
+		separator: getSeparator(s.Separator),
+		parse: func(string) error {
+			return nil
+		},
+	}
+	flagset.Var(s.flagDestination, s.Name, s.Description)
+}
+
+func (s *StringSliceVariable) getFlagValue(set *flag.FlagSet) (interface{}, bool) {
+	if !isFlagSet(set, flagNames(s)...) {
+		return nil, false
+	} else {
+		return append([]string{}, s.flagDestination.values...), true
+	}
+}
+
+func (s *StringSliceVariable) setEnv(value string, envName string) (interface{}, error) {
+	return splitSliceValue(value, getSeparator(s.Separator)), nil
+}
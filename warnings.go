@@ -0,0 +1,174 @@
+package unpuzzled
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+)
+
+// A deprecated variable or alias that was set, returned from app.Warnings.
+type Warning struct {
+	Command  string
+	Variable string
+	// The deprecated alias that was used, empty if the variable is deprecated.
+	Alias   string
+	Source  ParsingType
+	Message string
+	// The variable the value was forwarded to, if the variable has ReplacedBy set.
+	ReplacedBy string
+	// The name of the source, set for sources registered with the App.
+	SourceName string
+}
+
+func (w *Warning) String() string {
+	name := w.Variable
+	if w.Alias != "" {
+		name = fmt.Sprintf("%s (alias %s)", w.Variable, w.Alias)
+	}
+	out := fmt.Sprintf("%s.%s set from %s is deprecated", w.Command, name, sourceString(w.Source, w.SourceName))
+	if w.Message != "" {
+		out += ": " + w.Message
+	}
+	if w.ReplacedBy != "" {
+		out += fmt.Sprintf(" (value forwarded to %s)", w.ReplacedBy)
+	}
+	return out
+}
+
+// Warnings for deprecated variables and aliases from the last run, available after Run or RunE.
+func (a *App) Warnings() []*Warning {
+	return a.warnings
+}
+
+// Add warnings for deprecated variables and aliases in a set of parsed values,
+// and forward values for variables with ReplacedBy to the replacement variable.
+func (a *App) checkDeprecated(settings []*activeSetting) []*activeSetting {
+	commandMap := a.Command.GetExpandedActiveCommmands()
+	// settings of each variable, so forwarded values don't override the replacement's own value from the same source.
+	setVariables := make(map[string]bool, len(settings))
+	for _, setting := range settings {
+		setVariables[setting.CommandPath+"."+setting.VariableName+"."+setting.Key] = true
+	}
+	out := make([]*activeSetting, 0, len(settings))
+	for _, setting := range settings {
+		command := commandMap[setting.CommandPath]
+		variableMap := command.GetVariableMap()
+		options := variableMap[setting.VariableName].options()
+
+		alias := strings.TrimLeft(setting.Alias, "-")
+		if aliasMessage, ok := options.DeprecatedAliases[alias]; ok {
+			a.addWarning(&Warning{
+				Command:    setting.CommandPath,
+				Variable:   setting.VariableName,
+				Alias:      alias,
+				Source:     setting.Source,
+				SourceName: a.sourceNames[setting.Source],
+				Message:    aliasMessage,
+			})
+		}
+		if options.Deprecated == "" && options.ReplacedBy == "" {
+			out = append(out, setting)
+			continue
+		}
+		a.addWarning(&Warning{
+			Command:    setting.CommandPath,
+			Variable:   setting.VariableName,
+			Source:     setting.Source,
+			SourceName: a.sourceNames[setting.Source],
+			Message:    options.Deprecated,
+			ReplacedBy: options.ReplacedBy,
+		})
+		if options.ReplacedBy == "" {
+			out = append(out, setting)
+			continue
+		}
+		replacement := variableMap[options.ReplacedBy]
+		if setVariables[setting.CommandPath+"."+replacement.GetName()+"."+setting.Key] {
+			continue
+		}
+		out = append(out, &activeSetting{
+			CommandPath:  setting.CommandPath,
+			VariableName: replacement.GetName(),
+			Value:        setting.Value,
+			Source:       setting.Source,
+			SettingName:  setting.SettingName,
+			Alias:        setting.VariableName,
+			Key:          setting.Key,
+			Destination:  replacement.GetDestination(),
+		})
+	}
+	return out
+}
+
+// Only one warning is kept for each variable, alias and source.
+func (a *App) addWarning(warning *Warning) {
+	for _, existing := range a.warnings {
+		if existing.Command == warning.Command && existing.Variable == warning.Variable &&
+			existing.Alias == warning.Alias && existing.Source == warning.Source {
+			return
+		}
+	}
+	a.warnings = append(a.warnings, warning)
+}
+
+// Ensure every ReplacedBy is a variable of the same type in the same command.
+func (c *Command) checkReplacements() error {
+	var err error
+	c.loopActiveCommands(func(command *Command) {
+		variableMap := command.GetVariableMap()
+		for _, variable := range command.Variables {
+			replacedBy := variable.options().ReplacedBy
+			if replacedBy == "" || err != nil {
+				continue
+			}
+			replacement, ok := variableMap[replacedBy]
+			if !ok {
+				err = &UnknownReplacementError{
+					Command:    command.GetExpandedName(),
+					Variable:   variable.GetName(),
+					ReplacedBy: replacedBy,
+				}
+			} else if variableType(variable) != variableType(replacement) {
+				err = &ReplacementTypeError{
+					Command:         command.GetExpandedName(),
+					Variable:        variable.GetName(),
+					Type:            variableType(variable),
+					ReplacedBy:      replacedBy,
+					ReplacementType: variableType(replacement),
+				}
+			}
+		}
+	})
+	return err
+}
+
+// The type of the values of a variable, the destination type of generic variables.
+func variableType(variable Variable) string {
+	if generic, ok := variable.(*GenericVariable); ok && generic.Destination != nil {
+		return fmt.Sprintf("%T", generic.Destination)
+	}
+	return fmt.Sprintf("%T", variable)
+}
+
+// Print the warnings for deprecated variables.
+func (a *App) PrintWarnings() {
+	if a.Silent || len(a.warnings) == 0 {
+		return
+	}
+	t := template.New("warnings")
+	funcMap := getBaseFuncMap(a.RemoveColor)
+	funcMap["sourceString"] = func(p ParsingType) string {
+		return a.sourceName(p)
+	}
+	t.Funcs(funcMap)
+	t.Parse(`---------------------------
+{{ bold (red "Deprecated Variables:") }}
+---------------------------
+{{ range $i, $w := . -}}
+{{ blue $w.Command }} {{ green "--"}}{{ green $w.Variable }}{{ if gt (len $w.Alias) 0 }} (alias {{ $w.Alias }}){{ end }} set from {{ sourceString $w.Source }}{{ if gt (len $w.Message) 0 }} : {{ noEscape $w.Message }}{{ end }}
+{{ if gt (len $w.ReplacedBy) 0 }}	value forwarded to {{ green "--" }}{{ green $w.ReplacedBy }}
+{{ end -}}
+{{ end }}`)
+	t.Execute(os.Stdout, a.warnings)
+}